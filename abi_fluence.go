@@ -0,0 +1,290 @@
+package wazerosqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// fluenceABI implements abi for the fluencelabs build of SQLite.
+//
+// The fluencelabs build doesn't return multiple values from its exported functions. Instead, they store their
+// results in a guest-allocated descriptor whose address can be retrieved via "get_result_ptr", and the size of the
+// variable-length result (e.g. text) via "get_result_size". Strings are passed as a pair of pointer and size.
+type fluenceABI struct {
+	// m is the module instance this belongs to.
+	m *sqliteModule
+	// open holds the function for "sqlite3_open_v2" in SQLite C interface.
+	open api.Function
+	// exec holds the function for "sqlite3_exec" in SQLite C interface.
+	exec api.Function
+	// getResultPtr holds the function for "get_result_ptr" which returns the pointer to the result of the last call.
+	getResultPtr api.Function
+	// getResultSize holds the function for "get_result_size" which returns the size of the result of the last call.
+	getResultSize api.Function
+	// prepare holds the function for "sqlite3_prepare_v2" in SQLite C interface.
+	prepare api.Function
+	// columnText holds the function for "sqlite3_column_text" in SQLite C interface.
+	columnText api.Function
+	// columnBlob holds the function for "sqlite3_column_blob" in SQLite C interface.
+	columnBlob api.Function
+	// columnName holds the function for "sqlite3_column_name" in SQLite C interface.
+	columnName api.Function
+	// errmsg holds the function for "sqlite3_errmsg" in SQLite C interface.
+	errmsg api.Function
+	// alloc holds the function for "allocate" which allocates a buffer in the guest memory.
+	alloc api.Function
+}
+
+func newFluenceABI(m *sqliteModule) *fluenceABI {
+	return &fluenceABI{
+		m:             m,
+		open:          m.export("sqlite3_open_v2"),
+		exec:          m.export("sqlite3_exec"),
+		getResultPtr:  m.export("get_result_ptr"),
+		getResultSize: m.export("get_result_size"),
+		alloc:         m.export("allocate"),
+		prepare:       m.export("sqlite3_prepare_v2"),
+		columnText:    m.export("sqlite3_column_text"),
+		columnBlob:    m.export("sqlite3_column_blob"),
+		columnName:    m.export("sqlite3_column_name"),
+		errmsg:        m.export("sqlite3_errmsg"),
+	}
+}
+
+// openDB implements abi.openDB.
+func (s *fluenceABI) openDB(ctx context.Context, name string, flags uint32) (uint32, error) {
+	dbNamePtr, dbNameSize, err := s.allocateString(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	vfsNamePtr, vfsNameSize, err := s.allocateString(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// Create the db.
+	if _, err = s.open.Call(ctx, dbNamePtr, dbNameSize, uint64(flags), vfsNamePtr, vfsNameSize); err != nil {
+		return 0, fmt.Errorf("failed to call sqlite3_open_v2: %w", err)
+	}
+
+	// Get the db handle.
+	res, err := s.resultPtr(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if rc, err := s.statusCode(ctx, res); err != nil {
+		return 0, err
+	} else if rc != sqliteOK {
+		return 0, newError(rc, "failed to open "+name, "")
+	}
+
+	dbHandle, ok := s.m.memory.ReadUint32Le(ctx, res+4)
+	if !ok {
+		return 0, fmt.Errorf("cannot read db pointer at %d", res+4)
+	}
+	return dbHandle, nil
+}
+
+// prepareStmt implements abi.prepareStmt.
+func (s *fluenceABI) prepareStmt(ctx context.Context, dbHandle uint32, query string) (uint32, error) {
+	queryPtr, querySize, err := s.allocateString(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get the prepared statement for the query.
+	if _, err = s.prepare.Call(ctx, uint64(dbHandle), queryPtr, querySize); err != nil {
+		return 0, fmt.Errorf("failed to call prepare query %s: %w", query, err)
+	}
+
+	res, err := s.resultPtr(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if rc, err := s.statusCode(ctx, res); err != nil {
+		return 0, err
+	} else if rc != sqliteOK {
+		return 0, newError(rc, s.errorMessage(ctx, dbHandle), query)
+	}
+
+	// Read the prepared statement's pointer.
+	stmt, ok := s.m.memory.ReadUint32Le(ctx, res+4)
+	if !ok || stmt == 0 {
+		return 0, fmt.Errorf("failed to read prepared statement at %d", res+4)
+	}
+	return stmt, nil
+}
+
+// execSql implements abi.execSql.
+func (s *fluenceABI) execSql(ctx context.Context, dbHandle uint32, query string) error {
+	queryPtr, querySize, err := s.allocateString(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	// Execute query.
+	if _, err = s.exec.Call(ctx, uint64(dbHandle), queryPtr, querySize, 0, 0); err != nil {
+		return fmt.Errorf("error execution query '%s': %w", query, err)
+	}
+
+	res, err := s.resultPtr(ctx)
+	if err != nil {
+		return err
+	}
+	rc, err := s.statusCode(ctx, res)
+	if err != nil || rc == sqliteOK {
+		return err
+	}
+
+	errMsgPtr, ok := s.m.memory.ReadUint32Le(ctx, res+4)
+	if !ok {
+		return fmt.Errorf("cannot read err msg ptr")
+	}
+
+	errMsgSize, ok := s.m.memory.ReadUint32Le(ctx, res+8)
+	if !ok {
+		return fmt.Errorf("cannot read err msg size")
+	}
+
+	var errMsg string
+	if errMsgSize != 0 {
+		raw, ok := s.m.memory.Read(ctx, errMsgPtr, errMsgSize)
+		if !ok {
+			return fmt.Errorf("cannot read err msg")
+		}
+		errMsg = string(raw)
+	}
+	return newError(rc, errMsg, query)
+}
+
+// readText implements abi.readText.
+func (s *fluenceABI) readText(ctx context.Context, stmt uint32, columnIndex uint32) (string, error) {
+	if _, err := s.columnText.Call(ctx, uint64(stmt), uint64(columnIndex)); err != nil {
+		return "", fmt.Errorf("failed to read %d-th column as text: %w", columnIndex, err)
+	}
+
+	raw, err := s.readResultBytes(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %d-th column text: %w", columnIndex, err)
+	}
+	return string(raw), nil
+}
+
+// readBlob implements abi.readBlob.
+func (s *fluenceABI) readBlob(ctx context.Context, stmt uint32, columnIndex uint32) ([]byte, error) {
+	if _, err := s.columnBlob.Call(ctx, uint64(stmt), uint64(columnIndex)); err != nil {
+		return nil, fmt.Errorf("failed to read %d-th column as blob: %w", columnIndex, err)
+	}
+
+	raw, err := s.readResultBytes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d-th column blob: %w", columnIndex, err)
+	}
+	blob := make([]byte, len(raw))
+	copy(blob, raw)
+	return blob, nil
+}
+
+// readColumnName implements abi.readColumnName.
+func (s *fluenceABI) readColumnName(ctx context.Context, stmt uint32, columnIndex uint32) (string, error) {
+	if _, err := s.columnName.Call(ctx, uint64(stmt), uint64(columnIndex)); err != nil {
+		return "", fmt.Errorf("failed to read %d-th column name: %w", columnIndex, err)
+	}
+
+	raw, err := s.readResultBytes(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %d-th column name: %w", columnIndex, err)
+	}
+	return string(raw), nil
+}
+
+// errorMessage implements abi.errorMessage.
+func (s *fluenceABI) errorMessage(ctx context.Context, dbHandle uint32) string {
+	if _, err := s.errmsg.Call(ctx, uint64(dbHandle)); err != nil {
+		return "unknown error"
+	}
+	raw, err := s.readResultBytes(ctx)
+	if err != nil {
+		return "unknown error"
+	}
+	return string(raw)
+}
+
+// bindBytes implements abi.bindBytes.
+func (s *fluenceABI) bindBytes(ctx context.Context, f api.Function, name string, stmt uint32, index int, b []byte) (int, error) {
+	ptr, size, err := s.allocateBytes(ctx, b)
+	if err != nil {
+		return 0, err
+	}
+	return s.m.callInt(ctx, f, name, uint64(stmt), uint64(index), ptr, size, sqliteTransient)
+}
+
+// readResultBytes reads the variable-length result of the last call, e.g. the text returned by sqlite3_column_text.
+//
+// Note: the returned slice is a view of the guest memory, so it must be copied before the next guest call.
+func (s *fluenceABI) readResultBytes(ctx context.Context) ([]byte, error) {
+	ptr, err := s.resultPtr(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.getResultSize.Call(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting result size: %w", err)
+	}
+
+	size := uint32(res[0])
+	raw, ok := s.m.memory.Read(ctx, ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("failed to read result(size=%d) at %d", size, ptr)
+	}
+	return raw, nil
+}
+
+// resultPtr returns the pointer to the result of the last call.
+func (s *fluenceABI) resultPtr(ctx context.Context) (uint32, error) {
+	res, err := s.getResultPtr.Call(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error getting result ptr: %w", err)
+	}
+	return uint32(res[0]), nil
+}
+
+// allocateString copies str into a newly allocated guest buffer.
+func (s *fluenceABI) allocateString(ctx context.Context, str string) (ptr, size uint64, err error) {
+	return s.allocateBytes(ctx, []byte(str))
+}
+
+// allocateBytes copies b into a newly allocated guest buffer.
+//
+// Note: at least one byte is allocated so that the pointer to an empty value is never NULL, which SQLite would
+// otherwise interpret as a NULL value when binding.
+func (s *fluenceABI) allocateBytes(ctx context.Context, b []byte) (ptr, size uint64, err error) {
+	allocSize := len(b)
+	if allocSize == 0 {
+		allocSize = 1
+	}
+
+	res, err := s.alloc.Call(ctx, uint64(allocSize), 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to allocate %d bytes: %w", allocSize, err)
+	}
+
+	ptr = res[0]
+
+	if ok := s.m.memory.Write(ctx, uint32(res[0]), b); !ok {
+		return 0, 0, fmt.Errorf("failed to write %d bytes at %d", len(b), ptr)
+	}
+	return ptr, uint64(len(b)), nil
+}
+
+// statusCode returns the result code stored at resultPtr.
+func (s *fluenceABI) statusCode(ctx context.Context, resultPtr uint32) (int, error) {
+	rc, ok := s.m.memory.ReadUint32Le(ctx, resultPtr)
+	if !ok {
+		return 0, fmt.Errorf("cannot read return code")
+	}
+	return int(int32(rc)), nil
+}
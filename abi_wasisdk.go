@@ -0,0 +1,257 @@
+package wazerosqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// wasiSDKABI implements abi for SQLite compiled with wasi-sdk, which follows the standard C ABI: strings are
+// NUL-terminated, results are written to out-pointers, and guest memory is managed with "malloc" and "free".
+type wasiSDKABI struct {
+	// m is the module instance this belongs to.
+	m *sqliteModule
+	// open holds the function for "sqlite3_open_v2" in SQLite C interface.
+	open api.Function
+	// exec holds the function for "sqlite3_exec" in SQLite C interface.
+	exec api.Function
+	// prepare holds the function for "sqlite3_prepare_v2" in SQLite C interface.
+	prepare api.Function
+	// columnText holds the function for "sqlite3_column_text" in SQLite C interface.
+	columnText api.Function
+	// columnBlob holds the function for "sqlite3_column_blob" in SQLite C interface.
+	columnBlob api.Function
+	// columnBytes holds the function for "sqlite3_column_bytes" in SQLite C interface.
+	columnBytes api.Function
+	// columnName holds the function for "sqlite3_column_name" in SQLite C interface.
+	columnName api.Function
+	// errmsg holds the function for "sqlite3_errmsg" in SQLite C interface.
+	errmsg api.Function
+	// malloc holds the function for "malloc" of the C standard library.
+	malloc api.Function
+	// free holds the function for "free" of the C standard library.
+	free api.Function
+}
+
+func newWasiSDKABI(m *sqliteModule) *wasiSDKABI {
+	return &wasiSDKABI{
+		m:           m,
+		open:        m.export("sqlite3_open_v2"),
+		exec:        m.export("sqlite3_exec"),
+		prepare:     m.export("sqlite3_prepare_v2"),
+		columnText:  m.export("sqlite3_column_text"),
+		columnBlob:  m.export("sqlite3_column_blob"),
+		columnBytes: m.export("sqlite3_column_bytes"),
+		columnName:  m.export("sqlite3_column_name"),
+		errmsg:      m.export("sqlite3_errmsg"),
+		malloc:      m.export("malloc"),
+		free:        m.export("free"),
+	}
+}
+
+// openDB implements abi.openDB.
+func (s *wasiSDKABI) openDB(ctx context.Context, name string, flags uint32) (uint32, error) {
+	namePtr, err := s.allocateCString(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer s.freePtr(ctx, namePtr)
+
+	ppDb, err := s.allocate(ctx, 4)
+	if err != nil {
+		return 0, err
+	}
+	defer s.freePtr(ctx, ppDb)
+
+	rc, err := s.m.callInt(ctx, s.open, "sqlite3_open_v2", uint64(namePtr), uint64(ppDb), uint64(flags), 0)
+	if err != nil {
+		return 0, err
+	}
+
+	dbHandle, ok := s.m.memory.ReadUint32Le(ctx, ppDb)
+	if !ok {
+		return 0, fmt.Errorf("cannot read db pointer at %d", ppDb)
+	}
+	if rc != sqliteOK {
+		// A handle is returned even on failure, unless allocation failed, and it must be closed.
+		errMsg := "failed to open " + name
+		if dbHandle != 0 {
+			errMsg = s.errorMessage(ctx, dbHandle)
+			_, _ = s.m.closeDB.Call(ctx, uint64(dbHandle))
+		}
+		return 0, newError(rc, errMsg, "")
+	}
+	return dbHandle, nil
+}
+
+// prepareStmt implements abi.prepareStmt.
+func (s *wasiSDKABI) prepareStmt(ctx context.Context, dbHandle uint32, query string) (uint32, error) {
+	queryPtr, err := s.allocateCString(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer s.freePtr(ctx, queryPtr)
+
+	ppStmt, err := s.allocate(ctx, 4)
+	if err != nil {
+		return 0, err
+	}
+	defer s.freePtr(ctx, ppStmt)
+
+	rc, err := s.m.callInt(ctx, s.prepare, "sqlite3_prepare_v2",
+		uint64(dbHandle), uint64(queryPtr), uint64(len(query)), uint64(ppStmt), 0)
+	if err != nil {
+		return 0, err
+	} else if rc != sqliteOK {
+		return 0, newError(rc, s.errorMessage(ctx, dbHandle), query)
+	}
+
+	// The statement is NULL if the query has no statement, e.g. only a comment.
+	stmt, ok := s.m.memory.ReadUint32Le(ctx, ppStmt)
+	if !ok || stmt == 0 {
+		return 0, fmt.Errorf("failed to read prepared statement at %d", ppStmt)
+	}
+	return stmt, nil
+}
+
+// execSql implements abi.execSql.
+func (s *wasiSDKABI) execSql(ctx context.Context, dbHandle uint32, query string) error {
+	queryPtr, err := s.allocateCString(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer s.freePtr(ctx, queryPtr)
+
+	rc, err := s.m.callInt(ctx, s.exec, "sqlite3_exec", uint64(dbHandle), uint64(queryPtr), 0, 0, 0)
+	if err != nil {
+		return fmt.Errorf("error execution query '%s': %w", query, err)
+	} else if rc != sqliteOK {
+		return newError(rc, s.errorMessage(ctx, dbHandle), query)
+	}
+	return nil
+}
+
+// readText implements abi.readText.
+func (s *wasiSDKABI) readText(ctx context.Context, stmt uint32, columnIndex uint32) (string, error) {
+	raw, err := s.readColumnBytes(ctx, s.columnText, stmt, columnIndex)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %d-th column as text: %w", columnIndex, err)
+	}
+	return string(raw), nil
+}
+
+// readBlob implements abi.readBlob.
+func (s *wasiSDKABI) readBlob(ctx context.Context, stmt uint32, columnIndex uint32) ([]byte, error) {
+	raw, err := s.readColumnBytes(ctx, s.columnBlob, stmt, columnIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d-th column as blob: %w", columnIndex, err)
+	}
+	blob := make([]byte, len(raw))
+	copy(blob, raw)
+	return blob, nil
+}
+
+// readColumnName implements abi.readColumnName.
+func (s *wasiSDKABI) readColumnName(ctx context.Context, stmt uint32, columnIndex uint32) (string, error) {
+	res, err := s.columnName.Call(ctx, uint64(stmt), uint64(columnIndex))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %d-th column name: %w", columnIndex, err)
+	}
+	return s.readCString(ctx, uint32(res[0]))
+}
+
+// bindBytes implements abi.bindBytes.
+func (s *wasiSDKABI) bindBytes(ctx context.Context, f api.Function, name string, stmt uint32, index int, b []byte) (int, error) {
+	// At least one byte is allocated so that the pointer to an empty value is never NULL.
+	ptr, err := s.allocate(ctx, uint32(len(b)+1))
+	if err != nil {
+		return 0, err
+	}
+	// SQLITE_TRANSIENT makes SQLite copy the value, so the buffer can be freed right away.
+	defer s.freePtr(ctx, ptr)
+
+	if ok := s.m.memory.Write(ctx, ptr, b); !ok {
+		return 0, fmt.Errorf("failed to write %d bytes at %d", len(b), ptr)
+	}
+	return s.m.callInt(ctx, f, name, uint64(stmt), uint64(index), uint64(ptr), uint64(len(b)), sqliteTransient)
+}
+
+// readColumnBytes calls f, either "sqlite3_column_text" or "sqlite3_column_blob", and reads as many bytes as
+// "sqlite3_column_bytes" returns. The order matters, as the former may convert the value which changes its size.
+//
+// Note: the returned slice is a view of the guest memory, so it must be copied before the next guest call.
+func (s *wasiSDKABI) readColumnBytes(ctx context.Context, f api.Function, stmt uint32, columnIndex uint32) ([]byte, error) {
+	res, err := f.Call(ctx, uint64(stmt), uint64(columnIndex))
+	if err != nil {
+		return nil, err
+	}
+	ptr := uint32(res[0])
+
+	size, err := s.m.callInt(ctx, s.columnBytes, "sqlite3_column_bytes", uint64(stmt), uint64(columnIndex))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := s.m.memory.Read(ctx, ptr, uint32(size))
+	if !ok {
+		return nil, fmt.Errorf("failed to read result(size=%d) at %d", size, ptr)
+	}
+	return raw, nil
+}
+
+// errorMessage implements abi.errorMessage.
+func (s *wasiSDKABI) errorMessage(ctx context.Context, dbHandle uint32) string {
+	res, err := s.errmsg.Call(ctx, uint64(dbHandle))
+	if err != nil {
+		return "unknown error"
+	}
+	msg, err := s.readCString(ctx, uint32(res[0]))
+	if err != nil {
+		return "unknown error"
+	}
+	return msg
+}
+
+// readCString reads the NUL-terminated string at ptr.
+func (s *wasiSDKABI) readCString(ctx context.Context, ptr uint32) (string, error) {
+	var b []byte
+	for p := ptr; ; p++ {
+		c, ok := s.m.memory.ReadByte(ctx, p)
+		if !ok {
+			return "", fmt.Errorf("failed to read string at %d", ptr)
+		} else if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+}
+
+// allocateCString copies str into a newly allocated guest buffer with the terminating NUL.
+func (s *wasiSDKABI) allocateCString(ctx context.Context, str string) (uint32, error) {
+	ptr, err := s.allocate(ctx, uint32(len(str)+1))
+	if err != nil {
+		return 0, err
+	}
+	if ok := s.m.memory.Write(ctx, ptr, append([]byte(str), 0)); !ok {
+		s.freePtr(ctx, ptr)
+		return 0, fmt.Errorf("failed to write %d bytes at %d", len(str)+1, ptr)
+	}
+	return ptr, nil
+}
+
+// allocate allocates size bytes with "malloc". The buffer must be released with freePtr.
+func (s *wasiSDKABI) allocate(ctx context.Context, size uint32) (uint32, error) {
+	res, err := s.malloc.Call(ctx, uint64(size))
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %d bytes: %w", size, err)
+	} else if res[0] == 0 {
+		return 0, fmt.Errorf("failed to allocate %d bytes: out of memory", size)
+	}
+	return uint32(res[0]), nil
+}
+
+// freePtr releases the buffer allocated with allocate.
+func (s *wasiSDKABI) freePtr(ctx context.Context, ptr uint32) {
+	_, _ = s.free.Call(ctx, uint64(ptr))
+}
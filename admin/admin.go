@@ -0,0 +1,276 @@
+// Package admin implements a web console for browsing and querying a database, meant to be mounted on an existing
+// HTTP server.
+//
+//	http.Handle("/admin/", http.StripPrefix("/admin", admin.New(pool, auth)))
+//
+// The console lists the tables and views, shows their rows, runs any SQL statement with its EXPLAIN QUERY PLAN, and
+// downloads a dump of the database as SQL. Since it can modify the database, every request must be authorized by an
+// AuthFunc, and SQL is only run from same-origin POST requests, so that other sites can't make a browser run it with
+// the user's credentials.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// maxRows is the number of rows shown at most for a table or a query.
+const maxRows = 1000
+
+//go:embed admin.html
+var page string
+
+var tmpl = template.Must(template.New("admin").Parse(page))
+
+// AuthFunc decides whether the request may access the console. If it returns false, the request is not served, and
+// AuthFunc is responsible for writing the response, e.g. 401 with a WWW-Authenticate header.
+type AuthFunc func(w http.ResponseWriter, r *http.Request) bool
+
+// BasicAuth returns an AuthFunc which requires the user and password via HTTP basic authentication.
+func BasicAuth(user, password string) AuthFunc {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		u, p, ok := r.BasicAuth()
+		// Both fields are always compared, in constant time, so that the timing doesn't tell which one is wrong.
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user))
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password))
+		if ok && userOK&passwordOK == 1 {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="wazero-sqlite admin"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+}
+
+// handler implements http.Handler.
+type handler struct {
+	// pool is the database the console is for.
+	pool *wazerosqlite.Pool
+	// auth authorizes every request, or is nil to deny all of them.
+	auth AuthFunc
+	// mux routes the authorized requests.
+	mux *http.ServeMux
+}
+
+// New returns the handler of the console for the database of the pool. auth is called on every request. If it is nil,
+// every request is denied with 403 Forbidden, so that the console is never exposed by mistake.
+func New(pool *wazerosqlite.Pool, auth AuthFunc) http.Handler {
+	h := &handler{pool: pool, auth: auth, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/", h.serveIndex)
+	h.mux.HandleFunc("/table", h.serveTable)
+	h.mux.HandleFunc("/query", h.serveQuery)
+	h.mux.HandleFunc("/dump", h.serveDump)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.auth == nil {
+		http.Error(w, "no AuthFunc is configured", http.StatusForbidden)
+		return
+	} else if !h.auth(w, r) {
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// view is the data the page is rendered with.
+type view struct {
+	// Tables are the names of the tables and views.
+	Tables []string
+	// Table is the name of the table shown, if any.
+	Table string
+	// SQL is the query shown, if any.
+	SQL string
+	// Plan is the EXPLAIN QUERY PLAN of SQL.
+	Plan *result
+	// Result is the rows of the table or the query.
+	Result *result
+	// Error is the error of the query, if any.
+	Error string
+}
+
+// result is a table of values.
+type result struct {
+	// Columns are the names of the columns.
+	Columns []string
+	// Rows are the values, each with len(Columns) elements.
+	Rows [][]string
+	// Truncated is true if there were more than maxRows rows.
+	Truncated bool
+}
+
+func (h *handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	h.render(w, r, &view{})
+}
+
+func (h *handler) serveTable(w http.ResponseWriter, r *http.Request) {
+	v := &view{Table: r.URL.Query().Get("name")}
+	err := h.read(r.Context(), func(db *wazerosqlite.DB) (err error) {
+		v.Result, err = query(r.Context(), db, "SELECT * FROM "+wazerosqlite.QuoteIdentifier(v.Table))
+		return
+	})
+	if err != nil {
+		v.Error = err.Error()
+	}
+	h.render(w, r, v)
+}
+
+func (h *handler) serveQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.render(w, r, &view{})
+		return
+	} else if !sameOrigin(r) {
+		http.Error(w, "cross-origin request", http.StatusForbidden)
+		return
+	}
+	v := &view{SQL: strings.TrimSpace(r.PostFormValue("sql"))}
+	if v.SQL == "" {
+		h.render(w, r, v)
+		return
+	} else if len(wazerosqlite.SplitStatements(v.SQL)) > 1 {
+		// Query only runs the first statement, so the others would be silently ignored.
+		v.Error = "only one statement can be run at a time"
+		h.render(w, r, v)
+		return
+	}
+
+	// The query may write, so it runs exclusively.
+	conn, err := h.pool.AcquireWriter(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	// The plan is best-effort, e.g. it isn't available for PRAGMA.
+	v.Plan, _ = query(r.Context(), conn.DB, "EXPLAIN QUERY PLAN "+v.SQL)
+	v.Result, err = query(r.Context(), conn.DB, v.SQL)
+	conn.Release()
+	if err != nil {
+		v.Error = err.Error()
+	}
+	h.render(w, r, v)
+}
+
+func (h *handler) serveDump(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	// The dump is the diff from an empty database.
+	empty, err := wazerosqlite.Open(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer empty.Close(ctx)
+
+	var stmts []string
+	if err = h.read(ctx, func(db *wazerosqlite.DB) (err error) {
+		stmts, err = wazerosqlite.DiffSQL(ctx, empty, db)
+		return
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/sql; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="dump.sql"`)
+	_, _ = w.Write([]byte("BEGIN;\n" + strings.Join(append(stmts, "COMMIT;"), "\n") + "\n"))
+}
+
+// sameOrigin returns true if the request comes from a page of the console itself, according to its Origin header, or
+// its Referer header if the browser didn't send Origin. Requests with neither are rejected.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	u, err := url.Parse(origin)
+	if origin == "" || err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+// render lists the tables into v and writes the page.
+func (h *handler) render(w http.ResponseWriter, r *http.Request, v *view) {
+	err := h.read(r.Context(), func(db *wazerosqlite.DB) error {
+		rows, err := db.Query(r.Context(), "SELECT name FROM sqlite_master "+
+			"WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			if err = rows.Scan(&name); err != nil {
+				return err
+			}
+			v.Tables = append(v.Tables, name)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = tmpl.Execute(w, v)
+}
+
+// read calls fn with a DB acquired for reading.
+func (h *handler) read(ctx context.Context, fn func(db *wazerosqlite.DB) error) error {
+	conn, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return fn(conn.DB)
+}
+
+// query runs the query on db and returns up to maxRows rows.
+func query(ctx context.Context, db *wazerosqlite.DB, sql string) (*result, error) {
+	rows, err := db.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := &result{}
+	if res.Columns, err = rows.Columns(); err != nil {
+		return nil, err
+	}
+
+	values := make([]wazerosqlite.Value, len(res.Columns))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if len(res.Rows) == maxRows {
+			res.Truncated = true
+			break
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String()
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
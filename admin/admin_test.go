@@ -0,0 +1,200 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// newServer starts the console on a pool with a table t, behind basic authentication as admin:secret.
+func newServer(t *testing.T) (*wazerosqlite.Pool, *httptest.Server) {
+	t.Helper()
+	ctx := context.Background()
+	pool, err := wazerosqlite.NewPool(ctx, 1, wazerosqlite.WithFile(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close(ctx) })
+	conn, err := pool.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = conn.Exec(ctx, "CREATE TABLE t (a); INSERT INTO t VALUES ('<x>')")
+	conn.Release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(New(pool, BasicAuth("admin", "secret")))
+	t.Cleanup(ts.Close)
+	return pool, ts
+}
+
+// do sends the request with the credentials, and returns the status and the body of the response.
+func do(t *testing.T, req *http.Request) (int, string) {
+	t.Helper()
+	req.SetBasicAuth("admin", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// postQuery posts sql to the query page from the origin.
+func postQuery(t *testing.T, ts *httptest.Server, origin, sql string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/query", strings.NewReader(url.Values{"sql": {sql}}.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return do(t, req)
+}
+
+// count returns the number of rows in the table t.
+func count(t *testing.T, pool *wazerosqlite.Pool) int {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	rows, err := conn.Query(ctx, "SELECT count(*) FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	if !rows.Next() || rows.Scan(&n) != nil {
+		t.Fatal(rows.Err())
+	}
+	return n
+}
+
+func TestAuth(t *testing.T) {
+	_, ts := newServer(t)
+
+	tests := []struct {
+		name           string
+		user, password string
+		want           int
+	}{
+		{name: "no credentials", want: http.StatusUnauthorized},
+		{name: "wrong password", user: "admin", password: "x", want: http.StatusUnauthorized},
+		{name: "wrong user", user: "x", password: "secret", want: http.StatusUnauthorized},
+		{name: "valid", user: "admin", password: "secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("no WWW-Authenticate header")
+			}
+		})
+	}
+
+	// Without an AuthFunc, everything is denied.
+	rec := httptest.NewRecorder()
+	New(nil, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d without an AuthFunc, want 403", rec.Code)
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	_, ts := newServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/table?name=t", nil)
+	status, body := do(t, req)
+	// The values are escaped.
+	if status != http.StatusOK || !strings.Contains(body, `href="table?name=t"`) ||
+		!strings.Contains(body, "<td>&lt;x&gt;</td>") {
+		t.Errorf("got %d: %s", status, body)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/dump", nil)
+	status, body = do(t, req)
+	want := "BEGIN;\nCREATE TABLE t (a);\nINSERT INTO \"t\"(\"a\") VALUES('<x>');\nCOMMIT;\n"
+	if status != http.StatusOK || body != want {
+		t.Errorf("got %d: %q, want %q", status, body, want)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	pool, ts := newServer(t)
+
+	status, body := postQuery(t, ts, ts.URL, "SELECT a, 1 + 1 AS b FROM t")
+	if status != http.StatusOK || !strings.Contains(body, "<th>b</th>") || !strings.Contains(body, "<td>2</td>") {
+		t.Errorf("got %d: %s", status, body)
+	}
+	if !strings.Contains(body, "Query plan") {
+		t.Errorf("no query plan: %s", body)
+	}
+
+	// A single statement may write, and may end with a semicolon.
+	status, body = postQuery(t, ts, ts.URL, "INSERT INTO t VALUES (2);")
+	if status != http.StatusOK || strings.Contains(body, `class="error"`) {
+		t.Errorf("got %d: %s", status, body)
+	}
+	if n := count(t, pool); n != 2 {
+		t.Errorf("got %d rows, want 2", n)
+	}
+
+	// Several statements are rejected rather than running only the first one.
+	status, body = postQuery(t, ts, ts.URL, "INSERT INTO t VALUES (3); DELETE FROM t")
+	if status != http.StatusOK || !strings.Contains(body, "only one statement can be run at a time") {
+		t.Errorf("got %d: %s", status, body)
+	}
+	if n := count(t, pool); n != 2 {
+		t.Errorf("got %d rows after several statements, want 2", n)
+	}
+
+	// Errors are shown on the page.
+	if _, body = postQuery(t, ts, ts.URL, "SELECT * FROM missing"); !strings.Contains(body, "no such table: missing") {
+		t.Errorf("no error: %s", body)
+	}
+}
+
+func TestQueryCrossOrigin(t *testing.T) {
+	pool, ts := newServer(t)
+
+	for _, origin := range []string{"", "https://evil.example.com"} {
+		if status, _ := postQuery(t, ts, origin, "DELETE FROM t"); status != http.StatusForbidden {
+			t.Errorf("origin %q: got %d, want 403", origin, status)
+		}
+	}
+	if n := count(t, pool); n != 1 {
+		t.Errorf("got %d rows after cross-origin requests, want 1", n)
+	}
+}
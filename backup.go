@@ -0,0 +1,21 @@
+package wazerosqlite
+
+import "context"
+
+// Backup writes a consistent copy of the database to the file `name` in the mounted directory, e.g. to snapshot an
+// in-memory database to disk. The file must not exist or be empty. The copy is also vacuumed, so it can be smaller
+// than the database.
+//
+// The directory is the one of WithFile, or WithDir for in-memory databases and the DBs of a Runtime. The copy can be
+// opened with WithFile or Runtime.OpenDatabase, or attached to another database with Attach.
+//
+// Note: the Wasm build of SQLite doesn't export sqlite3_backup_init, so this is implemented with VACUUM INTO, which
+// copies the database in a single read transaction instead of incrementally.
+func (db *DB) Backup(ctx context.Context, name string) error {
+	return db.BackupSchema(ctx, "main", name)
+}
+
+// BackupSchema is like Backup, but copies the attached database `schema` instead of the main one.
+func (db *DB) BackupSchema(ctx context.Context, schema, name string) error {
+	return db.Exec(ctx, "VACUUM "+QuoteIdentifier(schema)+" INTO "+QuoteLiteral(TextValue(guestPath(name))))
+}
@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azureVersion is the version of the Blob service REST API requested, which allows blobs of up to 5000 MiB to be put
+// in a single request.
+const azureVersion = "2020-04-08"
+
+// AzureStore is a BlobStore in an Azure Blob Storage container. It uses the REST API through net/http, so that this
+// module doesn't depend on the Azure SDK.
+//
+//	store := &backup.AzureStore{
+//		ContainerURL: "https://account.blob.core.windows.net/backups",
+//		SAS:          os.Getenv("BACKUP_SAS"),
+//	}
+type AzureStore struct {
+	// Client sends the requests. Defaults to http.DefaultClient, which is enough with SAS. Otherwise, it must
+	// authenticate the requests, e.g. with a bearer token of Azure AD.
+	Client *http.Client
+	// ContainerURL is the URL of the container, e.g. "https://account.blob.core.windows.net/backups".
+	ContainerURL string
+	// SAS is a shared access signature granting access to the container, as a query string without the leading
+	// "?", or empty if Client authenticates the requests.
+	SAS string
+	// Prefix is prepended to the names of the blobs in the store to get the names of the Azure blobs, e.g. "app/".
+	Prefix string
+}
+
+// Put implements BlobStore.Put. The content is spooled to a temporary file first, as Azure needs its length, and
+// then put as a block blob in a single request, so that the blob only exists once it is complete. This limits blobs
+// to 5000 MiB.
+func (s *AzureStore) Put(ctx context.Context, name string, r io.Reader) error {
+	tmp, err := os.CreateTemp("", "wazero-sqlite-azure-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(s.blobPath(name), nil), io.NopCloser(tmp))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+	return s.do(req, "put", name, nil)
+}
+
+// Get implements BlobStore.Get.
+func (s *AzureStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(s.blobPath(name), nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", azureVersion)
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("get", name, resp)
+	}
+	return resp.Body, nil
+}
+
+// List implements BlobStore.List.
+func (s *AzureStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	q := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {s.Prefix + prefix}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url("", q), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Names      []string `xml:"Blobs>Blob>Name"`
+			NextMarker string   `xml:"NextMarker"`
+		}
+		if err = s.do(req, "list", prefix, &page); err != nil {
+			return nil, err
+		}
+
+		// Blobs are listed in lexical order already.
+		for _, name := range page.Names {
+			names = append(names, strings.TrimPrefix(name, s.Prefix))
+		}
+		if page.NextMarker == "" {
+			return names, nil
+		}
+		q.Set("marker", page.NextMarker)
+	}
+}
+
+// Delete implements BlobStore.Delete.
+func (s *AzureStore) Delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url(s.blobPath(name), nil), nil)
+	if err != nil {
+		return err
+	}
+	if err = s.do(req, "delete", name, nil); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *AzureStore) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+// blobPath returns the escaped path of the Azure blob of the blob `name`, relative to the container.
+func (s *AzureStore) blobPath(name string) string {
+	segments := strings.Split(s.Prefix+name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// url returns the URL of the path relative to the container, with the query q and SAS.
+func (s *AzureStore) url(path string, q url.Values) string {
+	query := q.Encode()
+	if s.SAS != "" {
+		if query != "" {
+			query += "&"
+		}
+		query += strings.TrimPrefix(s.SAS, "?")
+	}
+	u := strings.TrimSuffix(s.ContainerURL, "/") + path
+	if query != "" {
+		u += "?" + query
+	}
+	return u
+}
+
+// do sends the request about the blob `name`, and decodes the XML response into v if it isn't nil.
+func (s *AzureStore) do(req *http.Request, op, name string, v any) error {
+	req.Header.Set("x-ms-version", azureVersion)
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return responseError(op, name, resp)
+	} else if v == nil {
+		return nil
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", op, name, err)
+	}
+	return nil
+}
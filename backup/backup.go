@@ -0,0 +1,300 @@
+// Package backup implements a checksummed incremental backup format for database files.
+//
+// A backup is a chain of generations. The first generation is a full copy of the database, and each following
+// generation contains only the pages which changed since its parent. Every generation carries a manifest with the
+// checksums of all the pages of the database at that point, so that the next generation can be computed without
+// reading the previous ones, and so that a restore can be verified page by page.
+//
+// The database file must not be written while a generation is taken, e.g. by holding
+// wazerosqlite.Pool.AcquireWriter, since SQLite in the guest can't lock the file.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// magic is the header every generation starts with. The last byte is the format version.
+var magic = []byte("WZSQLBK\x01")
+
+// castagnoli is the CRC-32C table used for page checksums.
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is returned when a generation fails validation.
+var ErrCorrupt = errors.New("backup is corrupt")
+
+// maxPageCount is the largest number of pages of a database, SQLITE_MAX_PAGE_COUNT.
+const maxPageCount = 1073741823
+
+// checksumsChunk is the number of checksums ReadManifest reads at a time, so that the memory allocated for a
+// truncated manifest is bounded by its actual length rather than by the page count it claims.
+const checksumsChunk = 64 << 10
+
+// Manifest describes a generation.
+type Manifest struct {
+	// Generation is the number of this generation, which is higher than the numbers of the generations it is based
+	// on. BackupToStore numbers every generation, full or not, one past the highest in the store.
+	Generation uint64
+	// Parent is the generation this one is based on, or zero for a full backup.
+	Parent uint64
+	// CreatedAt is when this generation was taken.
+	CreatedAt time.Time
+	// PageSize is the page size of the database.
+	PageSize int
+	// Checksums are the CRC-32C checksums of all the pages of the database, indexed by page number minus one.
+	Checksums []uint32
+}
+
+// PageCount returns the number of pages in the database at this generation.
+func (m *Manifest) PageCount() uint32 {
+	return uint32(len(m.Checksums))
+}
+
+// BackupIncremental writes a generation of the database file at dbPath to w, and returns its manifest.
+//
+// If prev is nil, the generation is a full backup numbered 1. Otherwise, only the pages whose checksum differs from
+// prev are written, and the generation is numbered after prev. A full backup is required after the page size of the
+// database changes.
+func BackupIncremental(dbPath string, prev *Manifest, w io.Writer) (*Manifest, error) {
+	generation := uint64(1)
+	if prev != nil {
+		generation = prev.Generation + 1
+	}
+	return backupGeneration(dbPath, prev, generation, w)
+}
+
+// backupGeneration implements BackupIncremental, numbering the generation as given.
+func backupGeneration(dbPath string, prev *Manifest, generation uint64, w io.Writer) (*Manifest, error) {
+	f, err := os.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hb := make([]byte, 100)
+	if _, err = f.ReadAt(hb, 0); err != nil {
+		return nil, fmt.Errorf("failed to read database header: %w", err)
+	}
+	h, err := wazerosqlite.ParseHeader(hb)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{Generation: generation, CreatedAt: time.Now(), PageSize: h.PageSize}
+	if prev != nil {
+		if prev.PageSize != h.PageSize {
+			return nil, fmt.Errorf("page size changed from %d to %d: take a full backup", prev.PageSize, h.PageSize)
+		}
+		m.Parent = prev.Generation
+	}
+
+	// First pass: checksum all the pages to find the changed ones.
+	var changed []uint32
+	page := make([]byte, h.PageSize)
+	r := bufio.NewReaderSize(f, h.PageSize)
+	for pgno := uint32(1); ; pgno++ {
+		if _, err = io.ReadFull(r, page); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", pgno, err)
+		}
+
+		sum := crc32.Checksum(page, castagnoli)
+		m.Checksums = append(m.Checksums, sum)
+		if prev == nil || pgno > prev.PageCount() || prev.Checksums[pgno-1] != sum {
+			changed = append(changed, pgno)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if err = writeManifest(bw, m); err != nil {
+		return nil, err
+	}
+	if err = binary.Write(bw, binary.BigEndian, uint32(len(changed))); err != nil {
+		return nil, err
+	}
+
+	// Second pass: write the changed pages.
+	for _, pgno := range changed {
+		if _, err = f.ReadAt(page, int64(pgno-1)*int64(h.PageSize)); err != nil {
+			return nil, fmt.Errorf("failed to read page %d: %w", pgno, err)
+		}
+		sum := crc32.Checksum(page, castagnoli)
+		if sum != m.Checksums[pgno-1] {
+			return nil, fmt.Errorf("page %d changed during backup", pgno)
+		}
+
+		if err = binary.Write(bw, binary.BigEndian, [2]uint32{pgno, sum}); err != nil {
+			return nil, err
+		}
+		if _, err = bw.Write(page); err != nil {
+			return nil, err
+		}
+	}
+	return m, bw.Flush()
+}
+
+// ReadManifest reads the manifest at the beginning of a generation.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	b := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, fmt.Errorf("failed to read magic: %w", err)
+	} else if !bytes.Equal(b, magic) {
+		return nil, fmt.Errorf("%w: unknown magic %q", ErrCorrupt, b)
+	}
+
+	var fixed struct {
+		Generation, Parent uint64
+		CreatedAt          int64
+		PageSize           uint32
+		PageCount          uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// The page size is a power of two between 512 and 65536.
+	if fixed.PageSize < 512 || fixed.PageSize > 65536 || fixed.PageSize&(fixed.PageSize-1) != 0 {
+		return nil, fmt.Errorf("%w: invalid page size %d", ErrCorrupt, fixed.PageSize)
+	} else if fixed.PageCount > maxPageCount {
+		return nil, fmt.Errorf("%w: invalid page count %d", ErrCorrupt, fixed.PageCount)
+	}
+
+	m := &Manifest{
+		Generation: fixed.Generation,
+		Parent:     fixed.Parent,
+		CreatedAt:  time.Unix(0, fixed.CreatedAt),
+		PageSize:   int(fixed.PageSize),
+	}
+	chunk := make([]uint32, checksumsChunk)
+	for remaining := int(fixed.PageCount); remaining > 0; remaining -= len(chunk) {
+		if remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+		if err := binary.Read(r, binary.BigEndian, chunk); err != nil {
+			return nil, fmt.Errorf("failed to read checksums: %w", err)
+		}
+		m.Checksums = append(m.Checksums, chunk...)
+	}
+	return m, nil
+}
+
+func writeManifest(w io.Writer, m *Manifest) error {
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	fixed := []any{m.Generation, m.Parent, m.CreatedAt.UnixNano(), uint32(m.PageSize), m.PageCount(), m.Checksums}
+	for _, v := range fixed {
+		if err := binary.Write(w, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreChain restores the database file at dstPath from the generations, which must be ordered from the full
+// backup to the latest incremental one. Every page is verified against its checksum, and the restored file is moved
+// to dstPath only once it matches the manifest of the last generation.
+func RestoreChain(dstPath string, generations ...io.Reader) (*Manifest, error) {
+	if len(generations) == 0 {
+		return nil, errors.New("no generation to restore")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dstPath), filepath.Base(dstPath)+".restore-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var last *Manifest
+	for _, g := range generations {
+		if last, err = restoreGeneration(tmp, g, last); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = verify(tmp, last); err != nil {
+		return nil, err
+	}
+	if err = tmp.Sync(); err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+	return last, os.Rename(tmp.Name(), dstPath)
+}
+
+// restoreGeneration applies the generation read from r to f, after checking that it follows prev.
+func restoreGeneration(f *os.File, r io.Reader, prev *Manifest) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	m, err := ReadManifest(br)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case prev == nil && m.Parent != 0:
+		return nil, fmt.Errorf("chain must start with a full backup, but generation %d has parent %d",
+			m.Generation, m.Parent)
+	case prev != nil && m.Parent != prev.Generation:
+		return nil, fmt.Errorf("generation %d has parent %d, not %d", m.Generation, m.Parent, prev.Generation)
+	case prev != nil && m.PageSize != prev.PageSize:
+		return nil, fmt.Errorf("%w: page size of generation %d differs from its parent", ErrCorrupt, m.Generation)
+	}
+
+	var n uint32
+	if err = binary.Read(br, binary.BigEndian, &n); err != nil {
+		return nil, fmt.Errorf("failed to read page count of generation %d: %w", m.Generation, err)
+	}
+
+	page := make([]byte, m.PageSize)
+	for i := uint32(0); i < n; i++ {
+		var rec [2]uint32
+		if err = binary.Read(br, binary.BigEndian, &rec); err != nil {
+			return nil, fmt.Errorf("failed to read page of generation %d: %w", m.Generation, err)
+		}
+		if _, err = io.ReadFull(br, page); err != nil {
+			return nil, fmt.Errorf("failed to read page %d of generation %d: %w", rec[0], m.Generation, err)
+		}
+
+		pgno, sum := rec[0], rec[1]
+		if pgno == 0 || pgno > m.PageCount() || crc32.Checksum(page, castagnoli) != sum || m.Checksums[pgno-1] != sum {
+			return nil, fmt.Errorf("%w: page %d of generation %d", ErrCorrupt, pgno, m.Generation)
+		}
+		if _, err = f.WriteAt(page, int64(pgno-1)*int64(m.PageSize)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The database may have shrunk since the parent.
+	if err = f.Truncate(int64(m.PageCount()) * int64(m.PageSize)); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// verify checks all the pages of f against the manifest.
+func verify(f *os.File, m *Manifest) error {
+	page := make([]byte, m.PageSize)
+	for i, sum := range m.Checksums {
+		if _, err := f.ReadAt(page, int64(i)*int64(m.PageSize)); err != nil {
+			return fmt.Errorf("failed to read page %d: %w", i+1, err)
+		}
+		if crc32.Checksum(page, castagnoli) != sum {
+			return fmt.Errorf("%w: page %d doesn't match the manifest of generation %d", ErrCorrupt, i+1, m.Generation)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestReadManifest(t *testing.T) {
+	m := &Manifest{
+		Generation: 3,
+		Parent:     2,
+		CreatedAt:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		PageSize:   4096,
+		Checksums:  []uint32{1, 2, 3},
+	}
+	var buf bytes.Buffer
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	valid := buf.Bytes()
+
+	got, err := ReadManifest(bytes.NewReader(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(m.CreatedAt) || got.Generation != 3 || got.Parent != 2 || got.PageSize != 4096 ||
+		!reflect.DeepEqual(got.Checksums, m.Checksums) {
+		t.Errorf("got %+v, want %+v", got, m)
+	}
+
+	// Every truncation fails.
+	for n := 0; n < len(valid); n++ {
+		if _, err = ReadManifest(bytes.NewReader(valid[:n])); err == nil {
+			t.Errorf("read a manifest truncated to %d bytes", n)
+		}
+	}
+
+	// patch returns the valid manifest with the big-endian uint32 at offset replaced by v. The page size is at 32,
+	// after the magic and three 64-bit fields, followed by the page count.
+	patch := func(offset int, v uint32) []byte {
+		b := append([]byte(nil), valid...)
+		binary.BigEndian.PutUint32(b[offset:], v)
+		return b
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "magic", data: append([]byte("WZSQLBK\x02"), valid[8:]...)},
+		{name: "page size too small", data: patch(32, 256)},
+		{name: "page size too large", data: patch(32, 1<<17)},
+		{name: "page size not a power of two", data: patch(32, 4000)},
+		{name: "page count too large", data: patch(36, 0xffffffff)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadManifest(bytes.NewReader(tt.data)); !errors.Is(err, ErrCorrupt) {
+				t.Errorf("got %v, want ErrCorrupt", err)
+			}
+		})
+	}
+
+	// A valid page count larger than the data fails once the data runs out, without allocating checksums for all
+	// the pages it claims.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = ReadManifest(bytes.NewReader(patch(36, maxPageCount)))
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("read a manifest with missing checksums")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("allocated %d bytes for a truncated manifest", allocated)
+	}
+}
@@ -0,0 +1,281 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedMagic is the header every encrypted stream starts with. The last byte is the format version.
+var encryptedMagic = []byte("WZSQLENC\x01")
+
+// chunkSize is the size of the plaintext sealed at once in an encrypted stream.
+const chunkSize = 64 << 10
+
+// KeyFunc returns the AES key of the ID, which must be 16, 24 or 32 bytes long, e.g. by fetching it from a secrets
+// manager. The ID is stored in the clear with each encrypted stream, so that keys can be rotated.
+type KeyFunc func(ctx context.Context, keyID string) ([]byte, error)
+
+// NewEncryptWriter returns a writer which encrypts what is written to it with AES-GCM and the key keyID, and writes
+// the result to w. Close must be called to write the end of the stream, which is required for decryption.
+//
+// The stream is sealed in chunks, so that it can be encrypted and decrypted without buffering it all, and chunks
+// can't be reordered, dropped or truncated without decryption failing.
+func NewEncryptWriter(ctx context.Context, w io.Writer, keyID string, keys KeyFunc) (io.WriteCloser, error) {
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("key ID is longer than 255 bytes: %q", keyID)
+	}
+
+	header := append(append(encryptedMagic[:len(encryptedMagic):len(encryptedMagic)], byte(len(keyID))), keyID...)
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce[:8]); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce[:8]...)
+
+	aead, err := newAEAD(ctx, keyID, keys)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header, nonce: nonce}, nil
+}
+
+// newAEAD returns AES-GCM with the key keyID.
+func newAEAD(ctx context.Context, keyID string, keys KeyFunc) (cipher.AEAD, error) {
+	key, err := keys(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %q: %w", keyID, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWriter is the io.WriteCloser returned by NewEncryptWriter.
+type encryptWriter struct {
+	// w is where the encrypted stream is written.
+	w io.Writer
+	// aead seals the chunks.
+	aead cipher.AEAD
+	// header is the header of the stream, which is authenticated with every chunk.
+	header []byte
+	// nonce is the random prefix of the nonces followed by the counter of chunks.
+	nonce []byte
+	// buf holds the plaintext not sealed yet.
+	buf []byte
+	// closed is true after Close.
+	closed bool
+}
+
+// Write implements io.Writer.
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write after close")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		m := chunkSize - len(e.buf)
+		if m > len(p) {
+			m = len(p)
+		}
+		e.buf, p = append(e.buf, p[:m]...), p[m:]
+		// A full chunk is sealed only once more data arrives, so that the last chunk is never empty.
+		if len(e.buf) == chunkSize && len(p) > 0 {
+			if err := e.seal(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close seals the last chunk. It doesn't close the underlying writer.
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// seal encrypts buf as a chunk and writes it.
+func (e *encryptWriter) seal(final bool) error {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+
+	sealed := e.aead.Seal(nil, e.nonce, e.buf, append(e.header[:len(e.header):len(e.header)], flag))
+	var rec [5]byte
+	rec[0] = flag
+	binary.BigEndian.PutUint32(rec[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(rec[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+
+	e.buf = e.buf[:0]
+	counter := binary.BigEndian.Uint32(e.nonce[8:])
+	binary.BigEndian.PutUint32(e.nonce[8:], counter+1)
+	return nil
+}
+
+// NewDecryptReader returns a reader of the plaintext of the stream written by NewEncryptWriter. The key is looked up
+// with the ID stored in the stream. Reading fails with ErrCorrupt if the stream was modified or truncated.
+func NewDecryptReader(ctx context.Context, r io.Reader, keys KeyFunc) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(encryptedMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	} else if !bytes.Equal(header[:len(encryptedMagic)], encryptedMagic) {
+		return nil, fmt.Errorf("%w: not an encrypted stream", ErrCorrupt)
+	}
+
+	rest := make([]byte, int(header[len(encryptedMagic)])+8)
+	if _, err := io.ReadFull(br, rest); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	header = append(header, rest...)
+	keyID := string(rest[:len(rest)-8])
+
+	aead, err := newAEAD(ctx, keyID, keys)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 12)
+	copy(nonce, rest[len(rest)-8:])
+	return &decryptReader{r: br, aead: aead, header: header, nonce: nonce}, nil
+}
+
+// decryptReader is the io.Reader returned by NewDecryptReader.
+type decryptReader struct {
+	// r is the encrypted stream.
+	r io.Reader
+	// aead opens the chunks.
+	aead cipher.AEAD
+	// header is the header of the stream, which is authenticated with every chunk.
+	header []byte
+	// nonce is the random prefix of the nonces followed by the counter of chunks.
+	nonce []byte
+	// buf holds the plaintext of the current chunk not read yet.
+	buf []byte
+	// final is true once the last chunk is opened.
+	final bool
+}
+
+// Read implements io.Reader.
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// open reads and decrypts the next chunk into buf.
+func (d *decryptReader) open() error {
+	var rec [5]byte
+	if _, err := io.ReadFull(d.r, rec[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: encrypted stream is truncated", ErrCorrupt)
+		}
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(rec[1:])
+	if size > chunkSize+uint32(d.aead.Overhead()) || rec[0] > 1 {
+		return fmt.Errorf("%w: invalid chunk", ErrCorrupt)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("%w: encrypted stream is truncated", ErrCorrupt)
+	}
+
+	plain, err := d.aead.Open(sealed[:0], d.nonce, sealed, append(d.header[:len(d.header):len(d.header)], rec[0]))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	d.buf, d.final = plain, rec[0] == 1
+	counter := binary.BigEndian.Uint32(d.nonce[8:])
+	binary.BigEndian.PutUint32(d.nonce[8:], counter+1)
+	return nil
+}
+
+// EncryptedStore is a BlobStore which encrypts the blobs of the underlying Store with NewEncryptWriter, e.g. so that
+// generations stored in the cloud are protected with a key from a secrets manager.
+type EncryptedStore struct {
+	// Store is where the encrypted blobs are stored.
+	Store BlobStore
+	// KeyID is the ID of the key new blobs are encrypted with.
+	KeyID string
+	// Keys returns the keys by ID. Blobs encrypted with older keys can still be read as long as Keys returns them.
+	Keys KeyFunc
+}
+
+// Put implements BlobStore.Put.
+func (s *EncryptedStore) Put(ctx context.Context, name string, r io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		ew, err := NewEncryptWriter(ctx, pw, s.KeyID, s.Keys)
+		if err == nil {
+			if _, err = io.Copy(ew, r); err == nil {
+				err = ew.Close()
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := s.Store.Put(ctx, name, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// Get implements BlobStore.Get.
+func (s *EncryptedStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := s.Store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewDecryptReader(ctx, rc, s.Keys)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, rc}, nil
+}
+
+// List implements BlobStore.List.
+func (s *EncryptedStore) List(ctx context.Context, prefix string) ([]string, error) {
+	return s.Store.List(ctx, prefix)
+}
+
+// Delete implements BlobStore.Delete.
+func (s *EncryptedStore) Delete(ctx context.Context, name string) error {
+	return s.Store.Delete(ctx, name)
+}
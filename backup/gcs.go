@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GCSStore is a BlobStore in a Google Cloud Storage bucket. It uses the JSON API through net/http, so that this module
+// doesn't depend on the Cloud SDK.
+//
+//	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+//	...
+//	store := &backup.GCSStore{Client: client, Bucket: "backups", Prefix: "app/"}
+type GCSStore struct {
+	// Client sends the requests, and must authenticate them, e.g. the client of golang.org/x/oauth2/google. Defaults
+	// to http.DefaultClient, e.g. for an emulator.
+	Client *http.Client
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Prefix is prepended to the names of the blobs to get the names of the objects, e.g. "app/".
+	Prefix string
+	// Endpoint is the URL of the API, e.g. of an emulator. Defaults to "https://storage.googleapis.com".
+	Endpoint string
+}
+
+// Put implements BlobStore.Put. The object is uploaded in a single request, so it only exists once it is complete.
+func (s *GCSStore) Put(ctx context.Context, name string, r io.Reader) error {
+	q := url.Values{"uploadType": {"media"}, "name": {s.Prefix + name}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.endpoint()+"/upload/storage/v1/b/"+url.PathEscape(s.Bucket)+"/o?"+q.Encode(), r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	return s.do(req, "put", name, nil)
+}
+
+// Get implements BlobStore.Get.
+func (s *GCSStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(name)+"?alt=media", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("get", name, resp)
+	}
+	return resp.Body, nil
+}
+
+// List implements BlobStore.List.
+func (s *GCSStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	q := url.Values{"prefix": {s.Prefix + prefix}, "fields": {"items(name),nextPageToken"}}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+			s.endpoint()+"/storage/v1/b/"+url.PathEscape(s.Bucket)+"/o?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err = s.do(req, "list", prefix, &page); err != nil {
+			return nil, err
+		}
+
+		// Objects are listed in lexical order already.
+		for _, item := range page.Items {
+			names = append(names, strings.TrimPrefix(item.Name, s.Prefix))
+		}
+		if page.NextPageToken == "" {
+			return names, nil
+		}
+		q.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Delete implements BlobStore.Delete.
+func (s *GCSStore) Delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(name), nil)
+	if err != nil {
+		return err
+	}
+	if err = s.do(req, "delete", name, nil); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *GCSStore) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+func (s *GCSStore) endpoint() string {
+	if s.Endpoint == "" {
+		return "https://storage.googleapis.com"
+	}
+	return strings.TrimSuffix(s.Endpoint, "/")
+}
+
+// objectURL returns the URL of the object of the blob `name`.
+func (s *GCSStore) objectURL(name string) string {
+	return s.endpoint() + "/storage/v1/b/" + url.PathEscape(s.Bucket) + "/o/" + url.PathEscape(s.Prefix+name)
+}
+
+// do sends the request about the blob `name`, and decodes the JSON response into v if it isn't nil.
+func (s *GCSStore) do(req *http.Request, op, name string, v any) error {
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return responseError(op, name, resp)
+	} else if v == nil {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", op, name, err)
+	}
+	return nil
+}
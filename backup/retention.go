@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RetentionPolicy decides which generations in a BlobStore to keep. The latest generation is always kept.
+//
+// The periods are in UTC, and a period is kept by keeping the latest generation taken in it. Since an incremental
+// generation can't be restored without its parents, the generations it is based on are kept too.
+type RetentionPolicy struct {
+	// Hourly is the number of the latest hours to keep a generation of.
+	Hourly int
+	// Daily is the number of the latest days to keep a generation of.
+	Daily int
+	// Weekly is the number of the latest ISO weeks to keep a generation of.
+	Weekly int
+}
+
+// PruneReport lists the generations kept and deleted by Prune.
+type PruneReport struct {
+	// Kept are the generations kept, in order.
+	Kept []uint64
+	// Deleted are the generations deleted, or to be deleted in a dry run, in order.
+	Deleted []uint64
+	// Reasons are why each kept generation is kept, e.g. "daily" or "parent of 42".
+	Reasons map[uint64][]string
+}
+
+// Prune deletes the generations in the store which the policy doesn't keep, and returns what it did. If dryRun is
+// true, nothing is deleted and the report tells what would be.
+//
+// Prune must not run concurrently with BackupToStore on the same store.
+func Prune(ctx context.Context, store BlobStore, policy RetentionPolicy, dryRun bool) (*PruneReport, error) {
+	generations, err := Generations(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make(map[uint64]*Manifest, len(generations))
+	for _, g := range generations {
+		if manifests[g], err = readStoredManifest(ctx, store, g); err != nil {
+			return nil, fmt.Errorf("failed to read generation %d: %w", g, err)
+		}
+	}
+
+	report := &PruneReport{Reasons: map[uint64][]string{}}
+	keep := func(g uint64, reason string) {
+		report.Reasons[g] = append(report.Reasons[g], reason)
+	}
+	if len(generations) > 0 {
+		keep(generations[len(generations)-1], "latest")
+	}
+	for _, p := range []struct {
+		reason string
+		count  int
+		period func(t time.Time) string
+	}{
+		{"hourly", policy.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{"daily", policy.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{"weekly", policy.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+	} {
+		seen := map[string]bool{}
+		// Walk from the latest generation so that the latest one of each period is kept.
+		for i := len(generations) - 1; i >= 0 && len(seen) < p.count; i-- {
+			period := p.period(manifests[generations[i]].CreatedAt.UTC())
+			if !seen[period] {
+				seen[period] = true
+				keep(generations[i], p.reason)
+			}
+		}
+	}
+
+	// Keep the chains the kept generations are based on. Parents have lower numbers, so walking from the latest
+	// generation visits every parent after its children.
+	for i := len(generations) - 1; i >= 0; i-- {
+		g := generations[i]
+		if _, ok := report.Reasons[g]; !ok {
+			continue
+		}
+		if parent := manifests[g].Parent; parent != 0 {
+			if _, ok := manifests[parent]; !ok {
+				return nil, fmt.Errorf("%w: parent %d of generation %d is missing", ErrCorrupt, parent, g)
+			}
+			keep(parent, fmt.Sprintf("parent of %d", g))
+		}
+	}
+
+	for _, g := range generations {
+		if _, ok := report.Reasons[g]; ok {
+			report.Kept = append(report.Kept, g)
+		} else {
+			report.Deleted = append(report.Deleted, g)
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, g := range report.Deleted {
+		if err = store.Delete(ctx, generationName(g)); err != nil {
+			return report, fmt.Errorf("failed to delete generation %d: %w", g, err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// putManifest stores a generation without pages, which is enough for the functions reading only manifests.
+func putManifest(t *testing.T, store BlobStore, generation, parent uint64, createdAt time.Time) {
+	t.Helper()
+	var buf bytes.Buffer
+	m := &Manifest{Generation: generation, Parent: parent, CreatedAt: createdAt, PageSize: 4096}
+	if err := writeManifest(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write([]byte{0, 0, 0, 0})
+	if err := store.Put(context.Background(), generationName(generation), &buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPruneOldChains(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		kept    []uint64
+		deleted []uint64
+	}{
+		{
+			name:    "latest only",
+			kept:    []uint64{4, 5},
+			deleted: []uint64{1, 2, 3},
+		},
+		{
+			name:    "two days",
+			policy:  RetentionPolicy{Daily: 2},
+			kept:    []uint64{1, 2, 3, 4, 5},
+			deleted: nil,
+		},
+		{
+			name:    "two hours",
+			policy:  RetentionPolicy{Hourly: 2},
+			kept:    []uint64{4, 5},
+			deleted: []uint64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := DirStore(t.TempDir())
+			// A chain of a full backup and two incremental ones on the first day, then a new full backup with an
+			// incremental one on the next day.
+			putManifest(t, store, 1, 0, base)
+			putManifest(t, store, 2, 1, base.Add(time.Hour))
+			putManifest(t, store, 3, 2, base.Add(2*time.Hour))
+			putManifest(t, store, 4, 0, base.Add(24*time.Hour))
+			putManifest(t, store, 5, 4, base.Add(25*time.Hour))
+
+			report, err := Prune(ctx, store, tt.policy, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(report.Kept, tt.kept) || !reflect.DeepEqual(report.Deleted, tt.deleted) {
+				t.Errorf("kept %v and deleted %v, want %v and %v", report.Kept, report.Deleted, tt.kept, tt.deleted)
+			}
+			generations, err := Generations(ctx, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(generations, tt.kept) {
+				t.Errorf("store has %v, want %v", generations, tt.kept)
+			}
+		})
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	ctx := context.Background()
+	store := DirStore(t.TempDir())
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	putManifest(t, store, 1, 0, base)
+	putManifest(t, store, 2, 0, base.Add(time.Hour))
+
+	report, err := Prune(ctx, store, RetentionPolicy{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Deleted, []uint64{1}) {
+		t.Errorf("deleted %v, want [1]", report.Deleted)
+	}
+	if generations, _ := Generations(ctx, store); len(generations) != 2 {
+		t.Errorf("dry run deleted generations, store has %v", generations)
+	}
+}
@@ -0,0 +1,217 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BlobStore is where generations are stored, e.g. a local directory or a bucket of an object storage.
+//
+// DirStore, GCSStore and AzureStore implement it. The cloud stores use the REST APIs through net/http, so that this
+// module doesn't depend on the SDKs, and other object storages can be supported by implementing BlobStore.
+type BlobStore interface {
+	// Put stores the content of r as the blob `name`, replacing it if it exists. The blob must not be visible to Get
+	// or List until it is completely written.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get opens the blob `name`. It returns an error wrapping fs.ErrNotExist if the blob doesn't exist.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of the blobs starting with prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the blob `name`. It is not an error if the blob doesn't exist.
+	Delete(ctx context.Context, name string) error
+}
+
+// DirStore is a BlobStore in a local directory, where each blob is a file.
+type DirStore string
+
+// Put implements BlobStore.Put.
+func (d DirStore) Put(_ context.Context, name string, r io.Reader) error {
+	tmp, err := os.CreateTemp(string(d), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, r); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(string(d), name))
+}
+
+// Get implements BlobStore.Get.
+func (d DirStore) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), name))
+}
+
+// List implements BlobStore.List.
+func (d DirStore) List(_ context.Context, prefix string) ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasPrefix(e.Name(), prefix) {
+			names = append(names, e.Name())
+		}
+	}
+	// ReadDir sorts by file name already.
+	return names, nil
+}
+
+// Delete implements BlobStore.Delete.
+func (d DirStore) Delete(_ context.Context, name string) error {
+	if err := os.Remove(filepath.Join(string(d), name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// responseError returns the error of the unsuccessful response of a cloud store to the operation on the blob `name`,
+// which wraps fs.ErrNotExist if the blob doesn't exist.
+func responseError(op, name string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("failed to %s %s: %s: %s", op, name, resp.Status, bytes.TrimSpace(body))
+}
+
+// generationPrefix is the prefix of the names of generations in a BlobStore.
+const generationPrefix = "generation-"
+
+// generationName returns the name of the generation in a BlobStore, which sorts in the order of generations.
+func generationName(generation uint64) string {
+	return fmt.Sprintf("%s%020d", generationPrefix, generation)
+}
+
+// parseGenerationName returns the generation of the name in a BlobStore, or false if it isn't one.
+func parseGenerationName(name string) (uint64, bool) {
+	if !strings.HasPrefix(name, generationPrefix) {
+		return 0, false
+	}
+	g, err := strconv.ParseUint(strings.TrimPrefix(name, generationPrefix), 10, 64)
+	return g, err == nil
+}
+
+// Generations returns the numbers of the generations in the store, in order.
+func Generations(ctx context.Context, store BlobStore) ([]uint64, error) {
+	names, err := store.List(ctx, generationPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var generations []uint64
+	for _, name := range names {
+		if g, ok := parseGenerationName(name); ok {
+			generations = append(generations, g)
+		}
+	}
+	sort.Slice(generations, func(i, j int) bool { return generations[i] < generations[j] })
+	return generations, nil
+}
+
+// BackupToStore is like BackupIncremental, but writes the generation to the store. The generation is numbered one
+// past the highest in the store, so that a new full backup doesn't replace an older chain.
+func BackupToStore(ctx context.Context, store BlobStore, dbPath string, prev *Manifest) (*Manifest, error) {
+	generations, err := Generations(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	generation := uint64(1)
+	if len(generations) > 0 {
+		generation = generations[len(generations)-1] + 1
+	}
+	if prev != nil && prev.Generation >= generation {
+		generation = prev.Generation + 1
+	}
+
+	pr, pw := io.Pipe()
+	type result struct {
+		m   *Manifest
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		m, err := backupGeneration(dbPath, prev, generation, pw)
+		pw.CloseWithError(err)
+		done <- result{m, err}
+	}()
+
+	err = store.Put(ctx, generationName(generation), pr)
+	pr.CloseWithError(err)
+
+	res := <-done
+	if res.err != nil {
+		return nil, res.err
+	} else if err != nil {
+		return nil, err
+	}
+	return res.m, nil
+}
+
+// RestoreFromStore restores the database file at dstPath from the latest generation in the store and the chain of
+// generations it is based on. See RestoreChain.
+func RestoreFromStore(ctx context.Context, store BlobStore, dstPath string) (*Manifest, error) {
+	chain, err := latestChain(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+	return restoreStoredChain(ctx, store, dstPath, chain)
+}
+
+// restoreStoredChain restores the database file at dstPath from the chain of generations in the store.
+func restoreStoredChain(ctx context.Context, store BlobStore, dstPath string, chain []uint64) (*Manifest, error) {
+	readers := make([]io.Reader, len(chain))
+	for i, g := range chain {
+		rc, err := store.Get(ctx, generationName(g))
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		readers[i] = rc
+	}
+	return RestoreChain(dstPath, readers...)
+}
+
+// latestChain returns the generations from the full backup the latest generation in the store is based on to the
+// latest generation.
+func latestChain(ctx context.Context, store BlobStore) ([]uint64, error) {
+	generations, err := Generations(ctx, store)
+	if err != nil {
+		return nil, err
+	} else if len(generations) == 0 {
+		return nil, errors.New("no generation in the store")
+	}
+
+	// The generations of other chains may sit between the latest one and its parents, so the Parent links are
+	// followed rather than the order of the generations.
+	return chainOf(ctx, store, generations[len(generations)-1])
+}
+
+// readStoredManifest reads the manifest of the generation in the store.
+func readStoredManifest(ctx context.Context, store BlobStore, generation uint64) (*Manifest, error) {
+	rc, err := store.Get(ctx, generationName(generation))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ReadManifest(rc)
+}
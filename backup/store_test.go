@@ -0,0 +1,243 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLatestChain(t *testing.T) {
+	ctx := context.Background()
+	store := DirStore(t.TempDir())
+	now := time.Now()
+	// Generation 3 is based on the older full backup 1, after the full backup 2 was taken.
+	putManifest(t, store, 1, 0, now)
+	putManifest(t, store, 2, 0, now)
+	putManifest(t, store, 3, 1, now)
+
+	chain, err := latestChain(ctx, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{1, 3}; !reflect.DeepEqual(chain, want) {
+		t.Errorf("got %v, want %v", chain, want)
+	}
+}
+
+// testBlobStore checks the behavior of a BlobStore which BackupToStore, RestoreFromStore and Prune rely on.
+func testBlobStore(t *testing.T, store BlobStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, name := range []string{generationName(2), generationName(1), "other"} {
+		if err := store.Put(ctx, name, strings.NewReader("content of "+name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Put replaces the blob.
+	if err := store.Put(ctx, generationName(1), strings.NewReader("new content")); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := store.List(ctx, generationPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{generationName(1), generationName(2)}; !reflect.DeepEqual(names, want) {
+		t.Errorf("List returned %v, want %v", names, want)
+	}
+
+	rc, err := store.Get(ctx, generationName(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(b) != "new content" {
+		t.Errorf("Get returned %q, %v", b, err)
+	}
+
+	if err = store.Delete(ctx, generationName(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Delete(ctx, generationName(1)); err != nil {
+		t.Errorf("deleting a missing blob: %v", err)
+	}
+	if _, err = store.Get(ctx, generationName(1)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of a deleted blob returned %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	testBlobStore(t, DirStore(t.TempDir()))
+}
+
+// fakeObjects is an in-memory object storage for the fake servers.
+type fakeObjects struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeObjects) put(name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.objects == nil {
+		f.objects = map[string][]byte{}
+	}
+	f.objects[name] = b
+	return nil
+}
+
+func (f *fakeObjects) get(name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, ok := f.objects[name]
+	return b, ok
+}
+
+func (f *fakeObjects) delete(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.objects[name]
+	delete(f.objects, name)
+	return ok
+}
+
+// list returns the names with the prefix in order, one at a time to exercise paging.
+func (f *fakeObjects) list(prefix, after string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for name := range f.objects {
+		if strings.HasPrefix(name, prefix) && name > after {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "", false
+	}
+	return names[0], len(names) > 1
+}
+
+func TestGCSStore(t *testing.T) {
+	var objects fakeObjects
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const objectsPath = "/storage/v1/b/bucket/o"
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/upload"+objectsPath:
+			if r.URL.Query().Get("uploadType") != "media" {
+				http.Error(w, "bad upload type", http.StatusBadRequest)
+				return
+			}
+			if err := objects.put(r.URL.Query().Get("name"), r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, "{}")
+		case r.Method == http.MethodGet && r.URL.Path == objectsPath:
+			name, more := objects.list(r.URL.Query().Get("prefix"), r.URL.Query().Get("pageToken"))
+			resp := map[string]any{}
+			if name != "" {
+				resp["items"] = []map[string]string{{"name": name}}
+			}
+			if more {
+				resp["nextPageToken"] = name
+			}
+			json.NewEncoder(w).Encode(resp)
+		case strings.HasPrefix(r.URL.Path, objectsPath+"/"):
+			name := strings.TrimPrefix(r.URL.Path, objectsPath+"/")
+			if r.Method == http.MethodDelete {
+				if !objects.delete(name) {
+					http.NotFound(w, r)
+				}
+			} else if b, ok := objects.get(name); !ok {
+				http.NotFound(w, r)
+			} else if r.URL.Query().Get("alt") == "media" {
+				w.Write(b)
+			}
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	testBlobStore(t, &GCSStore{Bucket: "bucket", Prefix: "app/", Endpoint: srv.URL})
+	if _, ok := objects.get("app/" + generationName(2)); !ok {
+		t.Error("the prefix isn't prepended to the object names")
+	}
+}
+
+func TestAzureStore(t *testing.T) {
+	var objects fakeObjects
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("sig") != "secret" || r.Header.Get("x-ms-version") == "" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/container" && q.Get("comp") == "list" {
+			name, more := objects.list(q.Get("prefix"), q.Get("marker"))
+			var resp struct {
+				XMLName    xml.Name `xml:"EnumerationResults"`
+				Names      []string `xml:"Blobs>Blob>Name"`
+				NextMarker string   `xml:"NextMarker"`
+			}
+			if name != "" {
+				resp.Names = []string{name}
+			}
+			if more {
+				resp.NextMarker = name
+			}
+			xml.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/container/")
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" || r.ContentLength < 0 {
+				http.Error(w, "bad blob", http.StatusBadRequest)
+				return
+			}
+			if err := objects.put(name, r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			if b, ok := objects.get(name); ok {
+				w.Write(b)
+			} else {
+				http.NotFound(w, r)
+			}
+		case http.MethodDelete:
+			if objects.delete(name) {
+				w.WriteHeader(http.StatusAccepted)
+			} else {
+				http.NotFound(w, r)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	testBlobStore(t, &AzureStore{ContainerURL: srv.URL + "/container", SAS: "?sig=secret", Prefix: "app/"})
+	if _, ok := objects.get("app/" + generationName(2)); !ok {
+		t.Error("the prefix isn't prepended to the blob names")
+	}
+}
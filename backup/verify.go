@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// VerifyReport is the result of VerifyBackup.
+type VerifyReport struct {
+	// Manifest is the manifest of the verified generation.
+	Manifest *Manifest
+	// Problems are the errors reported by integrity_check, followed by the validation queries which failed.
+	Problems []string
+}
+
+// OK returns true if the generation passed all the checks.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyBackup restores the generation in the store with the chain it is based on into a temporary file, opens it in
+// a new SQLite module instance and runs "PRAGMA integrity_check" and the validation queries on it, so that backups
+// are known to restore before they are needed.
+//
+// A validation query passes if its first row has a true first column, e.g. "SELECT count(*) > 0 FROM users".
+//
+// An error is returned if the generation can't be restored, which wraps ErrCorrupt if it fails validation. Otherwise,
+// the problems found by the checks are in the report.
+func VerifyBackup(ctx context.Context, store BlobStore, generation uint64, queries ...string) (*VerifyReport, error) {
+	chain, err := chainOf(ctx, store, generation)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "wazero-sqlite-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "verify.db")
+	m, err := restoreStoredChain(ctx, store, path, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := wazerosqlite.Open(ctx, wazerosqlite.WithFile(path))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	report := &VerifyReport{Manifest: m}
+	if report.Problems, err = integrityCheck(ctx, db); err != nil {
+		return nil, err
+	}
+	for _, q := range queries {
+		if ok, err := validate(ctx, db, q); err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: %v", q, err))
+		} else if !ok {
+			report.Problems = append(report.Problems, q+": false")
+		}
+	}
+	return report, nil
+}
+
+// integrityCheck runs "PRAGMA integrity_check" and returns the problems it found.
+func integrityCheck(ctx context.Context, db *wazerosqlite.DB) ([]string, error) {
+	rows, err := db.Query(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err = rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// validate runs the validation query and returns whether the first column of its first row is true.
+func validate(ctx context.Context, db *wazerosqlite.DB, query string) (bool, error) {
+	rows, err := db.Query(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var ok bool
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	err = rows.Scan(&ok)
+	return ok, err
+}
+
+// chainOf returns the generations from the full backup the generation is based on to the generation itself.
+func chainOf(ctx context.Context, store BlobStore, generation uint64) ([]uint64, error) {
+	chain := []uint64{generation}
+	for g := generation; ; {
+		m, err := readStoredManifest(ctx, store, g)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generation %d: %w", g, err)
+		} else if m.Parent == 0 {
+			break
+		} else if m.Parent >= g {
+			// Parents have lower numbers, which also keeps a corrupt manifest from looping.
+			return nil, fmt.Errorf("%w: generation %d has parent %d", ErrCorrupt, g, m.Parent)
+		}
+		g = m.Parent
+		chain = append([]uint64{g}, chain...)
+	}
+	return chain, nil
+}
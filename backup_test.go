@@ -0,0 +1,81 @@
+package wazerosqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := Open(ctx, WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	err = db.Exec(ctx, `CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT);
+CREATE INDEX t_name ON t (name);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000)
+INSERT INTO t (name) SELECT 'name ' || i FROM n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An empty file is accepted as well as a missing one.
+	if err = os.WriteFile(filepath.Join(dir, "empty.db"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"copy.db", "empty.db"} {
+		if err = db.Backup(ctx, name); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		copied, err := Open(ctx, WithFile(filepath.Join(dir, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := queryStrings(t, copied,
+			"SELECT count(*) || ',' || max(name) || ',' || (SELECT name FROM sqlite_master WHERE type = 'index') FROM t")
+		copied.Close(ctx)
+		if err != nil || got[0] != "1000,name 999,t_name" {
+			t.Errorf("%s: got %v, %v", name, got, err)
+		}
+	}
+
+	// A file which isn't empty isn't overwritten.
+	if err = db.Backup(ctx, "copy.db"); err == nil {
+		t.Error("backed up over an existing database")
+	}
+}
+
+func TestBackupSchema(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := Open(ctx, WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	err = db.Exec(ctx, `CREATE TABLE main_only (a);
+ATTACH ':memory:' AS other;
+CREATE TABLE other.t (a);
+INSERT INTO other.t VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.BackupSchema(ctx, "other", "other.db"); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := Open(ctx, WithFile(filepath.Join(dir, "other.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close(ctx)
+	got, err := queryStrings(t, copied, "SELECT group_concat(name) FROM sqlite_master")
+	if err != nil || got[0] != "t" {
+		t.Errorf("got tables %v, %v", got, err)
+	}
+}
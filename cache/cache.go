@@ -0,0 +1,196 @@
+// Package cache implements a read-through cache stored in a table of a database, with entries expiring after a TTL,
+// e.g. to keep the responses of a slow upstream service across restarts.
+//
+//	c, err := cache.New(ctx, pool, "http_cache", 10*time.Minute)
+//	...
+//	body, err := c.GetOrFill(ctx, url, func() ([]byte, error) {
+//		return fetch(ctx, url)
+//	})
+//
+// Expired entries are evicted lazily: Get ignores them, and each Set deletes a bounded number of them, so that the
+// table doesn't grow with keys which are never read again.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// evictBatch is the number of expired entries deleted at most by each Set.
+const evictBatch = 100
+
+// errFillPanicked is returned to the calls of GetOrFill waiting for a fill which panicked.
+var errFillPanicked = errors.New("fill panicked")
+
+// Cache is a cache table of keys to values, which expire after the TTL of the cache.
+type Cache struct {
+	// pool is the database of the table.
+	pool *wazerosqlite.Pool
+	// table is the quoted name of the table.
+	table string
+	// ttl is how long an entry is valid after it is set.
+	ttl time.Duration
+	// now returns the current time, and is replaced in tests.
+	now func() time.Time
+
+	// mu guards fills.
+	mu sync.Mutex
+	// fills are the calls of fill functions in progress by key, so that concurrent calls of GetOrFill for the same key
+	// share them.
+	fills map[string]*fillCall
+}
+
+// fillCall is a call of the fill function of GetOrFill.
+type fillCall struct {
+	// done is closed once value and err are set.
+	done chan struct{}
+	// value and err are the result of the fill, after it is stored.
+	value []byte
+	err   error
+}
+
+// New returns the Cache in the table of the database of the pool, creating the table if it doesn't exist.
+func New(ctx context.Context, pool *wazerosqlite.Pool, table string, ttl time.Duration) (*Cache, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid TTL %s", ttl)
+	}
+
+	c := &Cache{
+		pool:  pool,
+		table: wazerosqlite.QuoteIdentifier(table),
+		ttl:   ttl,
+		now:   time.Now,
+		fills: map[string]*fillCall{},
+	}
+	conn, err := pool.AcquireWriter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	// The index on expires_at keeps the eviction from scanning the table.
+	err = conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT PRIMARY KEY,
+	value BLOB NOT NULL,
+	expires_at INTEGER NOT NULL
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS %s ON %[1]s (expires_at)`, c.table, wazerosqlite.QuoteIdentifier(table+"_expires_at")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the cache table: %w", err)
+	}
+	return c, nil
+}
+
+// Get returns the value of the key, and false if there is none or it has expired.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, "SELECT value FROM "+c.table+" WHERE key = ? AND expires_at > ?",
+		key, c.now().UnixMilli())
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, false, rows.Err()
+	}
+	var value []byte
+	if err = rows.Scan(&value); err != nil {
+		return nil, false, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, true, nil
+}
+
+// Set stores the value of the key, which expires after the TTL, and evicts some expired entries.
+func (c *Cache) Set(ctx context.Context, key string, value []byte) error {
+	// A nil slice would be bound as NULL.
+	if value == nil {
+		value = []byte{}
+	}
+	now := c.now()
+	return c.write(ctx, func(conn *wazerosqlite.Conn) error {
+		_, err := conn.ExecResult(ctx, "INSERT OR REPLACE INTO "+c.table+" (key, value, expires_at) VALUES (?, ?, ?)",
+			key, value, now.Add(c.ttl).UnixMilli())
+		if err != nil {
+			return err
+		}
+		_, err = conn.ExecResult(ctx, fmt.Sprintf("DELETE FROM %s WHERE key IN "+
+			"(SELECT key FROM %[1]s WHERE expires_at <= ? ORDER BY expires_at LIMIT %d)", c.table, evictBatch),
+			now.UnixMilli())
+		return err
+	})
+}
+
+// Delete removes the key from the cache, if it is there.
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	return c.write(ctx, func(conn *wazerosqlite.Conn) error {
+		_, err := conn.ExecResult(ctx, "DELETE FROM "+c.table+" WHERE key = ?", key)
+		return err
+	})
+}
+
+// write calls fn with a Conn acquired for writing.
+func (c *Cache) write(ctx context.Context, fn func(conn *wazerosqlite.Conn) error) error {
+	conn, err := c.pool.AcquireWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return fn(conn)
+}
+
+// GetOrFill returns the value of the key like Get, or if there is none or it has expired, calls fill and stores the
+// value it returns. Concurrent calls for the same key share a single call of fill, so that an expired entry doesn't
+// send a burst of requests to what the cache is in front of.
+//
+// The error of fill is returned as is, and nothing is stored. fill is called without holding a Conn of the pool.
+func (c *Cache) GetOrFill(ctx context.Context, key string, fill func() ([]byte, error)) ([]byte, error) {
+	value, ok, err := c.Get(ctx, key)
+	if err != nil || ok {
+		return value, err
+	}
+
+	c.mu.Lock()
+	f, filling := c.fills[key]
+	if !filling {
+		f = &fillCall{done: make(chan struct{})}
+		c.fills[key] = f
+	}
+	c.mu.Unlock()
+
+	if filling {
+		select {
+		case <-f.done:
+			return f.value, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.fills, key)
+		c.mu.Unlock()
+		close(f.done)
+	}()
+	f.err = errFillPanicked
+	if f.value, f.err = fill(); f.err != nil {
+		return nil, f.err
+	}
+	if err = c.Set(ctx, key, f.value); err != nil {
+		f.err = fmt.Errorf("failed to store the value: %w", err)
+		return nil, f.err
+	}
+	return f.value, nil
+}
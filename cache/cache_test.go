@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// clock is a fake clock for the cache.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newCache returns a Cache with a TTL of one minute on a new database, and its clock.
+func newCache(t *testing.T) (*Cache, *clock) {
+	t.Helper()
+	ctx := context.Background()
+	pool, err := wazerosqlite.NewPool(ctx, 2, wazerosqlite.WithFile(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pool.Close(ctx) })
+
+	c, err := New(ctx, pool, "cache", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clk := &clock{now: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	c.now = clk.Now
+	return c, clk
+}
+
+// assertGet checks the value of the key, where a nil want means that there is none.
+func assertGet(t *testing.T, c *Cache, key string, want []byte) {
+	t.Helper()
+	got, ok, err := c.Get(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok != (want != nil) || string(got) != string(want) {
+		t.Errorf("%s: got %q, %t, want %q", key, got, ok, want)
+	}
+}
+
+// count returns the number of entries in the table, expired or not.
+func count(t *testing.T, c *Cache) int {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	rows, err := conn.Query(ctx, "SELECT count(*) FROM "+c.table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	if !rows.Next() || rows.Scan(&n) != nil {
+		t.Fatal(rows.Err())
+	}
+	return n
+}
+
+func TestGetSet(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newCache(t)
+
+	assertGet(t, c, "a", nil)
+	if err := c.Set(ctx, "a", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "empty", nil); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, c, "a", []byte("x"))
+	assertGet(t, c, "empty", []byte{})
+
+	// Setting again replaces the value and extends the expiry.
+	clk.Advance(30 * time.Second)
+	if err := c.Set(ctx, "a", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	clk.Advance(30 * time.Second)
+	assertGet(t, c, "a", []byte("y"))
+	assertGet(t, c, "empty", nil)
+
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	assertGet(t, c, "a", nil)
+
+	// The table is reused.
+	c2, err := New(ctx, c.pool, "cache", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, c2); n != 1 {
+		t.Errorf("got %d entries, want 1", n)
+	}
+	if _, err = New(ctx, c.pool, "other", 0); err == nil {
+		t.Error("created a cache with no TTL")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newCache(t)
+
+	for i := 0; i < evictBatch+50; i++ {
+		if err := c.Set(ctx, fmt.Sprintf("k%d", i), []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clk.Advance(time.Minute)
+
+	// Each Set evicts a batch of expired entries.
+	if err := c.Set(ctx, "new", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, c); n != 51 {
+		t.Errorf("got %d entries, want 51", n)
+	}
+	if err := c.Set(ctx, "new", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, c); n != 1 {
+		t.Errorf("got %d entries, want 1", n)
+	}
+	assertGet(t, c, "new", []byte("y"))
+}
+
+func TestGetOrFill(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newCache(t)
+
+	calls := 0
+	fill := func() ([]byte, error) {
+		calls++
+		return []byte{byte('0' + calls)}, nil
+	}
+	for i, want := range []string{"1", "1"} {
+		got, err := c.GetOrFill(ctx, "k", fill)
+		if err != nil || string(got) != want {
+			t.Errorf("call %d: got %q, %v, want %q", i, got, err, want)
+		}
+	}
+
+	// An expired entry is filled again.
+	clk.Advance(time.Minute)
+	if got, err := c.GetOrFill(ctx, "k", fill); err != nil || string(got) != "2" {
+		t.Errorf("got %q, %v after the expiry", got, err)
+	}
+
+	// Errors aren't cached.
+	failed := errors.New("failed")
+	if _, err := c.GetOrFill(ctx, "e", func() ([]byte, error) { return nil, failed }); err != failed {
+		t.Errorf("got %v, want the error of fill", err)
+	}
+	assertGet(t, c, "e", nil)
+}
+
+func TestGetOrFillConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newCache(t)
+
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	fill := func() ([]byte, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return []byte("v"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 5)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrFill(ctx, "k", fill)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- string(v)
+		}()
+	}
+	// Wait until all the calls are either filling or waiting for the fill.
+	for {
+		c.mu.Lock()
+		started := c.fills["k"] != nil
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != "v" {
+			t.Errorf("got %q", v)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("fill was called %d times, want 1", calls)
+	}
+
+	// A waiting call ends with its context.
+	release = make(chan struct{})
+	defer close(release)
+	go c.GetOrFill(ctx, "slow", fill)
+	for {
+		c.mu.Lock()
+		started := c.fills["slow"] != nil
+		c.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetOrFill(timeoutCtx, "slow", fill); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
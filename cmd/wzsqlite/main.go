@@ -0,0 +1,275 @@
+// Command wzsqlite is an interactive shell for SQLite databases in the style of the sqlite3 shell, running SQLite in
+// wazero so that it needs neither CGO nor a system SQLite.
+//
+//	wzsqlite [-sysclock] [FILE]
+//
+// Without FILE, the database is in memory. Otherwise, the directory of FILE is mounted in the guest. SQL is read from
+// the standard input, and executed once a statement is complete. The results of queries are printed as tables.
+// Lines starting with a dot are commands, see ".help".
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// errExit is returned by commands which end the shell.
+var errExit = errors.New("exit")
+
+func main() {
+	sysClock := flag.Bool("sysclock", false, "give SQLite access to the host clock")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-sysclock] [FILE]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var opts []wazerosqlite.Option
+	if *sysClock {
+		opts = append(opts, wazerosqlite.WithSystemClock())
+	}
+	if flag.NArg() == 1 {
+		opts = append(opts, wazerosqlite.WithFile(flag.Arg(0)))
+	}
+
+	ctx := context.Background()
+	db, err := wazerosqlite.Open(ctx, opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer db.Close(ctx)
+
+	s := &shell{db: db, out: os.Stdout, interactive: isTerminal(os.Stdin)}
+	if err = s.run(ctx, os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		db.Close(ctx)
+		os.Exit(1)
+	}
+}
+
+// isTerminal returns true if f is a character device, in which case prompts are shown.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// shell reads SQL and commands, and prints their results.
+type shell struct {
+	// db is the database the SQL runs on.
+	db *wazerosqlite.DB
+	// out is where results are printed.
+	out io.Writer
+	// interactive is true if prompts are shown, and errors don't stop the shell.
+	interactive bool
+}
+
+// run reads the input until EOF or ".quit".
+func (s *shell) run(ctx context.Context, in io.Reader) error {
+	if s.interactive {
+		fmt.Fprintf(s.out, "wzsqlite: SQLite %s in wazero\nEnter \".help\" for usage hints.\n", s.version(ctx))
+	}
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(nil, 1<<20)
+	var buf strings.Builder
+	for {
+		s.prompt(buf.Len() == 0)
+		if !sc.Scan() {
+			break
+		}
+		line := sc.Text()
+
+		var err error
+		if buf.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ".") {
+			err = s.command(ctx, strings.TrimSpace(line))
+		} else {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+			if !wazerosqlite.Complete(buf.String()) {
+				continue
+			}
+			err = s.execute(ctx, buf.String())
+			buf.Reset()
+		}
+
+		if errors.Is(err, errExit) {
+			return nil
+		} else if err != nil {
+			if !s.interactive {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	// Like the sqlite3 shell, an unterminated statement at the end of the input is still executed.
+	if strings.TrimSpace(buf.String()) != "" {
+		return s.execute(ctx, buf.String())
+	}
+	return nil
+}
+
+// prompt shows the prompt for a new statement, or for the continuation of one.
+func (s *shell) prompt(first bool) {
+	if !s.interactive {
+		return
+	}
+	if first {
+		fmt.Fprint(s.out, "wzsqlite> ")
+	} else {
+		fmt.Fprint(s.out, "     ...> ")
+	}
+}
+
+// version returns the version of SQLite.
+func (s *shell) version(ctx context.Context) string {
+	var v string
+	rows, err := s.db.Query(ctx, "SELECT sqlite_version()")
+	if err != nil {
+		return "?"
+	}
+	defer rows.Close()
+	if !rows.Next() || rows.Scan(&v) != nil {
+		return "?"
+	}
+	return v
+}
+
+// execute runs the statements in sql one by one, and prints the rows of those which return some.
+func (s *shell) execute(ctx context.Context, sql string) error {
+	for _, stmt := range wazerosqlite.SplitStatements(sql) {
+		if err := s.query(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query runs the statement and prints its rows as a table.
+func (s *shell) query(ctx context.Context, stmt string, args ...any) error {
+	rows, err := s.db.Query(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]wazerosqlite.Value, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	header := false
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		// The header is printed only if there are rows, so that statements such as INSERT print nothing.
+		if !header {
+			fmt.Fprintln(tw, strings.Join(columns, "\t"))
+			dashes := make([]string, len(columns))
+			for i, c := range columns {
+				dashes[i] = strings.Repeat("-", len(c))
+			}
+			fmt.Fprintln(tw, strings.Join(dashes, "\t"))
+			header = true
+		}
+		for i, v := range values {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			if !v.IsNull() {
+				fmt.Fprint(tw, strings.NewReplacer("\t", " ", "\n", " ").Replace(v.Text()))
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// command runs the dot-command line.
+func (s *shell) command(ctx context.Context, line string) error {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".quit", ".exit":
+		return errExit
+	case ".help":
+		fmt.Fprint(s.out, `.dump            Print the database as SQL
+.help            Show this message
+.quit            Exit the shell
+.schema ?TABLE?  Show the CREATE statements, only those of TABLE if given
+.tables          List the tables and views
+`)
+		return nil
+	case ".tables":
+		return s.query(ctx, "SELECT name FROM sqlite_master "+
+			"WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name")
+	case ".schema":
+		if len(fields) > 1 {
+			return s.printSchema(ctx, "SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND tbl_name = ? "+
+				"ORDER BY type = 'table' DESC, name", fields[1])
+		}
+		return s.printSchema(ctx, "SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name "+
+			"NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY tbl_name, type = 'table' DESC, name")
+	case ".dump":
+		return s.dump(ctx)
+	}
+	return fmt.Errorf("unknown command %q, enter \".help\" for usage hints", fields[0])
+}
+
+// printSchema prints the SQL returned by the query, one statement per line.
+func (s *shell) printSchema(ctx context.Context, query string, args ...any) error {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sql string
+		if err = rows.Scan(&sql); err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, sql+";")
+	}
+	return rows.Err()
+}
+
+// dump prints the database as SQL, which is the diff from an empty database.
+func (s *shell) dump(ctx context.Context) error {
+	empty, err := wazerosqlite.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer empty.Close(ctx)
+
+	stmts, err := wazerosqlite.DiffSQL(ctx, empty, s.db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(s.out, "BEGIN;\n"+strings.Join(append(stmts, "COMMIT;"), "\n"))
+	return nil
+}
@@ -0,0 +1,156 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CopyOptions configures CopyTable. The zero value copies in batches of 1000 rows.
+type CopyOptions struct {
+	// BatchSize is the number of rows inserted in each transaction of the destination. Defaults to 1000.
+	BatchSize int
+	// Progress is called after each batch is committed with the number of rows copied so far, if set.
+	Progress func(rows int64)
+}
+
+// CopyTable copies the rows of the table from src to dst, which are separate module instances, e.g. the Conns of
+// Pools on two database files, and returns the number of rows copied. It is meant for migration and sharding tooling,
+// where the rows must be moved between databases rather than diffed.
+//
+// If the table doesn't exist in dst, it is created with the definition in src. The rows are streamed from src and
+// inserted in transactions of BatchSize rows, and the indexes and triggers of the table which don't exist in dst are
+// created after all the rows are copied, so that they are built once rather than updated on every insert. The values
+// keep their storage class, but the rowids of tables without an INTEGER PRIMARY KEY are assigned by dst.
+//
+// If the copy fails, the batches committed before the failure stay in dst, and their number of rows is returned with
+// the error.
+func CopyTable(ctx context.Context, src, dst *DB, table string, opts CopyOptions) (int64, error) {
+	if src == dst {
+		return 0, errors.New("source and destination are the same DB")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+
+	srcSchema, err := src.schema(ctx)
+	if err != nil {
+		return 0, err
+	}
+	def, ok := srcSchema[table]
+	if !ok || def.typ != "table" {
+		return 0, fmt.Errorf("no such table: %s", table)
+	}
+	dstSchema, err := dst.schema(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok = dstSchema[table]; !ok {
+		if err = dst.Exec(ctx, def.sql); err != nil {
+			return 0, fmt.Errorf("failed to create table %s: %w", table, err)
+		}
+	}
+
+	n, err := copyRows(ctx, src, dst, table, opts)
+	if err != nil {
+		return n, err
+	}
+
+	// Indexes are created before triggers, in the order of their names like DiffSQL.
+	for _, typ := range []string{"index", "trigger"} {
+		for _, name := range sortedNames(srcSchema) {
+			o := srcSchema[name]
+			if _, exists := dstSchema[name]; o.typ != typ || o.tblName != table || exists {
+				continue
+			}
+			if err = dst.Exec(ctx, o.sql); err != nil {
+				return n, fmt.Errorf("failed to create %s %s: %w", typ, name, err)
+			}
+		}
+	}
+	return n, nil
+}
+
+// copyRows streams the rows of the table from src into the same table in dst, in transactions of opts.BatchSize rows.
+func copyRows(ctx context.Context, src, dst *DB, table string, opts CopyOptions) (int64, error) {
+	rows, err := src.Query(ctx, "SELECT * FROM "+QuoteIdentifier(table))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = QuoteIdentifier(c)
+	}
+	insert, err := dst.Prepare(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", QuoteIdentifier(table),
+		strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close(ctx)
+
+	// n counts the rows inserted, and committed those in committed batches.
+	var n, committed int64
+	var tx *Tx
+	defer func() {
+		if tx != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	commit := func() error {
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		tx, committed = nil, n
+		if opts.Progress != nil {
+			opts.Progress(n)
+		}
+		return nil
+	}
+
+	args := make([]any, len(columns))
+	for {
+		row, ok, err := nextRow(rows, len(columns))
+		if err != nil {
+			return committed, err
+		} else if !ok {
+			break
+		}
+
+		if tx == nil {
+			if tx, err = dst.Begin(ctx); err != nil {
+				return committed, err
+			}
+		}
+		for i, v := range row {
+			// An empty BLOB reads as a nil slice, which would be bound as NULL.
+			if v.Type() == TypeBlob && len(v.Blob()) == 0 {
+				args[i] = []byte{}
+			} else {
+				args[i] = v
+			}
+		}
+		if err = insertRecord(ctx, insert, args); err != nil {
+			return committed, fmt.Errorf("failed to insert row %d: %w", n+1, err)
+		}
+		n++
+
+		if n-committed == int64(opts.BatchSize) {
+			if err = commit(); err != nil {
+				return committed, err
+			}
+		}
+	}
+	if tx != nil {
+		if err = commit(); err != nil {
+			return committed, err
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// copySource is a table with an index, and a trigger which would change the rows if it were created before them.
+const copySource = `CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, data BLOB);
+WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 2500)
+INSERT INTO t SELECT i, 'n' || i, CASE i % 3 WHEN 0 THEN NULL WHEN 1 THEN x'' ELSE randomblob(4) END FROM c;
+CREATE INDEX t_name ON t (name);
+CREATE TRIGGER t_upper AFTER INSERT ON t BEGIN UPDATE t SET name = upper(name) WHERE id = new.id; END;
+CREATE TABLE other (a);`
+
+func TestCopyTable(t *testing.T) {
+	ctx := context.Background()
+	src := openWith(t, copySource)
+	dst := openWith(t, "")
+
+	var progress []int64
+	n, err := CopyTable(ctx, src, dst, "t", CopyOptions{Progress: func(rows int64) { progress = append(progress, rows) }})
+	if err != nil || n != 2500 {
+		t.Fatalf("copied %d rows: %v", n, err)
+	}
+	if want := []int64{1000, 2000, 2500}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("got progress %v, want %v", progress, want)
+	}
+
+	// The rows, their storage classes, the index and the trigger are the same as in src, without the other table.
+	if err = src.Exec(ctx, "DROP TABLE other"); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := DiffSQL(ctx, src, dst)
+	if err != nil || len(diff) != 0 {
+		t.Errorf("got diff %v, %v", diff, err)
+	}
+	got, err := queryStrings(t, dst, "SELECT group_concat(DISTINCT typeof(data)) FROM (SELECT data FROM t ORDER BY id)")
+	if err != nil || got[0] != "blob,null" {
+		t.Errorf("got types %v, %v", got, err)
+	}
+
+	// Copying into an existing table appends the rows, and still defers the indexes and triggers missing in dst.
+	dst = openWith(t, "CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, data BLOB); INSERT INTO t VALUES (0, 'x', NULL)")
+	if n, err = CopyTable(ctx, src, dst, "t", CopyOptions{BatchSize: 100000}); err != nil || n != 2500 {
+		t.Fatalf("copied %d rows: %v", n, err)
+	}
+	got, err = queryStrings(t, dst, `SELECT count(*) || ' ' || min(name) || ' ' || max(name) || ' ' ||
+  (SELECT group_concat(name) FROM (SELECT name FROM sqlite_master WHERE type != 'table' ORDER BY name)) FROM t`)
+	if err != nil || got[0] != "2501 n1 x t_name,t_upper" {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestCopyTableErrors(t *testing.T) {
+	ctx := context.Background()
+	src := openWith(t, copySource)
+
+	if _, err := CopyTable(ctx, src, src, "t", CopyOptions{}); err == nil {
+		t.Error("copied into the same DB")
+	}
+	for _, table := range []string{"missing", "t_name"} {
+		if _, err := CopyTable(ctx, src, openWith(t, ""), table, CopyOptions{}); err == nil {
+			t.Errorf("copied %s", table)
+		}
+	}
+
+	// The batches before a failing row stay in dst.
+	dst := openWith(t, `CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, data BLOB);
+INSERT INTO t VALUES (1500, 'x', NULL)`)
+	n, err := CopyTable(ctx, src, dst, "t", CopyOptions{BatchSize: 1000})
+	if !errors.Is(err, CodeConstraint) || !strings.Contains(err.Error(), "row 1500") || n != 1000 {
+		t.Errorf("copied %d rows: %v", n, err)
+	}
+	got, err := queryStrings(t, dst, "SELECT count(*) || '' FROM t")
+	if err != nil || got[0] != "1001" {
+		t.Errorf("got %v rows, %v", got, err)
+	}
+	got, err = queryStrings(t, dst, "SELECT count(*) || '' FROM sqlite_master WHERE type != 'table'")
+	if err != nil || got[0] != "0" {
+		t.Errorf("got %v indexes and triggers after a failure, %v", got, err)
+	}
+}
@@ -0,0 +1,168 @@
+package wazerosqlite
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CSVOptions configures ImportCSV and ExportCSV. The zero value reads and writes comma-separated values with a header
+// row.
+type CSVOptions struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+	// NoHeader is true if the CSV has no header row. ImportCSV then inserts the fields into Columns, and ExportCSV
+	// writes only the rows.
+	NoHeader bool
+	// Columns are the columns ImportCSV inserts the fields into, in order. Defaults to the names in the header row.
+	Columns []string
+	// Null is the field ExportCSV writes for NULL, and which ImportCSV inserts as NULL, e.g. `\N`, so that NULL and
+	// empty text survive a round trip. By default, NULL is written as an empty field, and no field is read as NULL.
+	Null string
+}
+
+// ImportCSV inserts the records read from r into the table, like ".import" of the sqlite3 shell, and returns the
+// number of rows inserted.
+//
+// If the table doesn't exist, it is created with a column without type for each of the columns, so that values keep
+// the text they were read as. Every record must have as many fields as there are columns. The rows are inserted with
+// a prepared statement in a single transaction, so either all of them are imported or none is.
+func (db *DB) ImportCSV(ctx context.Context, table string, r io.Reader, opts CSVOptions) (int64, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.ReuseRecord = true
+
+	columns := opts.Columns
+	if !opts.NoHeader {
+		header, err := cr.Read()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read header: %w", err)
+		}
+		if columns == nil {
+			columns = append([]string(nil), header...)
+		}
+	}
+	if len(columns) == 0 {
+		return 0, errors.New("no columns to import into")
+	}
+	cr.FieldsPerRecord = len(columns)
+
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = QuoteIdentifier(c)
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)",
+		QuoteIdentifier(table), strings.Join(quoted, ", "))); err != nil {
+		return 0, err
+	}
+	insert, err := db.Prepare(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", QuoteIdentifier(table),
+		strings.Join(quoted, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close(ctx)
+
+	var n int64
+	args := make([]any, len(columns))
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, err
+		}
+		for i, field := range record {
+			if opts.Null != "" && field == opts.Null {
+				args[i] = nil
+			} else {
+				args[i] = field
+			}
+		}
+		if err = insertRecord(ctx, insert, args); err != nil {
+			return 0, fmt.Errorf("failed to insert record %d: %w", n+1, err)
+		}
+		n++
+	}
+	return n, tx.Commit(ctx)
+}
+
+// insertRecord executes the INSERT statement with args. Unlike Stmt.Exec, it doesn't query the Result, which would
+// double the guest calls per record.
+func insertRecord(ctx context.Context, insert *Stmt, args []any) error {
+	if err := insert.Reset(ctx); err != nil {
+		return err
+	}
+	if err := insert.bindArgs(ctx, args); err != nil {
+		return err
+	}
+	_, err := insert.Step(ctx)
+	return err
+}
+
+// ExportCSV writes the rows of the query to w as CSV, like ".mode csv" of the sqlite3 shell, and returns the number
+// of rows written. The header row has the column names. Rows are streamed, so the result set doesn't need to fit in
+// memory.
+//
+// args are bound to the parameters of the query like DB.Query. Values are written as their text representation, BLOBs
+// as their raw bytes, and NULL as the Null of opts. Columns of opts is ignored.
+func (db *DB) ExportCSV(ctx context.Context, query string, w io.Writer, opts CSVOptions, args ...any) (int64, error) {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !opts.NoHeader {
+		if err = cw.Write(columns); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int64
+	values := make([]Value, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return n, err
+		}
+		for i, v := range values {
+			if v.IsNull() {
+				record[i] = opts.Null
+			} else {
+				record[i] = v.Text()
+			}
+		}
+		if err = cw.Write(record); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		return n, err
+	}
+	cw.Flush()
+	return n, cw.Error()
+}
@@ -0,0 +1,106 @@
+package wazerosqlite
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := openWith(t, `CREATE TABLE t (id INTEGER, name TEXT, data BLOB);
+INSERT INTO t VALUES
+  (1, 'plain', x'00ff41'),
+  (2, NULL, NULL),
+  (3, '', x''),
+  (4, 'say "hi", then
+leave', 'text')`)
+
+	opts := CSVOptions{Null: `\N`}
+	var exported bytes.Buffer
+	n, err := db.ExportCSV(ctx, "SELECT * FROM t ORDER BY id", &exported, opts)
+	if err != nil || n != 4 {
+		t.Fatalf("exported %d rows: %v", n, err)
+	}
+	want := "id,name,data\n" +
+		"1,plain,\x00\xffA\n" +
+		"2,\\N,\\N\n" +
+		"3,,\n" +
+		"4,\"say \"\"hi\"\", then\nleave\",text\n"
+	if exported.String() != want {
+		t.Errorf("got %q, want %q", exported.String(), want)
+	}
+
+	if n, err = db.ImportCSV(ctx, "copy", bytes.NewReader(exported.Bytes()), opts); err != nil || n != 4 {
+		t.Fatalf("imported %d rows: %v", n, err)
+	}
+	var again bytes.Buffer
+	if _, err = db.ExportCSV(ctx, "SELECT * FROM copy ORDER BY rowid", &again, opts); err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != want {
+		t.Errorf("exported again: got %q, want %q", again.String(), want)
+	}
+
+	// NULL and empty text are told apart, and the bytes of BLOBs are kept, as TEXT.
+	got, err := queryStrings(t, db, `SELECT
+  (SELECT group_concat(id) FROM copy WHERE name IS NULL),
+  (SELECT group_concat(id) FROM copy WHERE name = ''),
+  (SELECT hex(data) || typeof(data) FROM copy WHERE id = '1')`)
+	if want := []string{"2", "3", "00FF41text"}; err != nil || strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestCSVDefaultNull(t *testing.T) {
+	ctx := context.Background()
+	db := openWith(t, "CREATE TABLE t (a, b); INSERT INTO t VALUES (NULL, '')")
+
+	var exported bytes.Buffer
+	if _, err := db.ExportCSV(ctx, "SELECT * FROM t", &exported, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if exported.String() != "a,b\n,\n" {
+		t.Errorf("got %q", exported.String())
+	}
+	// Without Null, empty fields are imported as empty text.
+	if _, err := db.ImportCSV(ctx, "copy", &exported, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := queryStrings(t, db, "SELECT typeof(a), typeof(b) FROM copy")
+	if err != nil || got[0] != "text" || got[1] != "text" {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
+
+func TestImportCSVOptions(t *testing.T) {
+	ctx := context.Background()
+	db := openWith(t, "CREATE TABLE t (x, y)")
+
+	// Without a header, the fields go into Columns.
+	n, err := db.ImportCSV(ctx, "t", strings.NewReader("1;a\n2;b\n"), CSVOptions{Comma: ';', NoHeader: true, Columns: []string{"y", "x"}})
+	if err != nil || n != 2 {
+		t.Fatalf("imported %d rows: %v", n, err)
+	}
+	var exported bytes.Buffer
+	if _, err = db.ExportCSV(ctx, "SELECT * FROM t WHERE y > ?", &exported, CSVOptions{Comma: ';', NoHeader: true}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if exported.String() != "b;2\n" {
+		t.Errorf("got %q", exported.String())
+	}
+
+	// A record with the wrong number of fields fails the whole import.
+	if _, err = db.ImportCSV(ctx, "t", strings.NewReader("x,y\n3,c\n4\n"), CSVOptions{}); err == nil {
+		t.Error("imported a record with a missing field")
+	}
+	got, err := queryStrings(t, db, "SELECT count(*) || '' FROM t")
+	if err != nil || got[0] != "2" {
+		t.Errorf("got %v rows, %v after a failed import", got, err)
+	}
+
+	if _, err = db.ImportCSV(ctx, "t", strings.NewReader(""), CSVOptions{}); err == nil {
+		t.Error("imported without a header")
+	}
+}
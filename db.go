@@ -0,0 +1,264 @@
+// Package wazerosqlite runs the Wasm-compiled SQLite VM on wazero, so that Go programs can use SQLite without CGO.
+package wazerosqlite
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// sqlite3Wasm is the Wasm binary compiled from the SQLite source code.
+// https://github.com/fluencelabs/sqlite/releases/tag/v0.16.0_w
+//
+//go:embed sqlite3.wasm
+var sqlite3Wasm []byte
+
+const (
+	// sqliteOK is SQLITE_OK result code.
+	sqliteOK = 0
+	// sqliteRow is SQLITE_ROW result code.
+	sqliteRow = 100
+	// sqliteDone is SQLITE_DONE result code.
+	sqliteDone = 101
+)
+
+// sqliteTransient is SQLITE_TRANSIENT destructor which makes SQLite copy the bound value.
+var sqliteTransient = api.EncodeI32(-1)
+
+const (
+	// openReadWrite is SQLITE_OPEN_READWRITE flag for sqlite3_open_v2.
+	openReadWrite = 0x2
+	// openCreate is SQLITE_OPEN_CREATE flag for sqlite3_open_v2.
+	openCreate = 0x4
+)
+
+// DB is a SQLite database opened in a dedicated Wasm module instance.
+//
+// Note: DB is not safe for concurrent use as the underlying module instance is single-threaded.
+type DB struct {
+	// closer releases the module instance, or the whole runtime if it is dedicated to this DB. It does nothing for
+	// the DBs of a Runtime, which share the module instance.
+	closer api.Closer
+	// m is the SQLite module instance.
+	m *sqliteModule
+	// handle is the identifier assigned to the opened database.
+	handle uint32
+	// invalidUTF8 is how invalid UTF-8 text is handled when reading columns.
+	invalidUTF8 InvalidUTF8Mode
+	// logger is where problems like leaked statements are reported.
+	logger *log.Logger
+	// strictScan is true to fail scans whose destination doesn't match the storage class of the column.
+	strictScan bool
+	// stats are logged on Close, or nil without WithCloseStats.
+	stats *closeStats
+	// stmts maps the handles of the statements not closed yet to their SQL.
+	//
+	// Note: this must not refer to Stmt, so that leaked statements can still be garbage collected and reported.
+	stmts map[uint32]string
+	// dir is the host directory mounted in the guest, or empty if none is.
+	dir string
+	// tempDir is the temporary directory removed on Close, if any.
+	tempDir string
+}
+
+// Open creates a new wazero runtime, instantiates SQLite in it and opens an in-memory database, or the file
+// specified by WithFile.
+//
+// The returned DB must be closed with DB.Close to release the runtime.
+func Open(ctx context.Context, opts ...Option) (*DB, error) {
+	c := newConfig(opts)
+
+	r, compiledSqlite, _, err := newRuntime(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := open(ctx, r, compiledSqlite, c, c.moduleConfig())
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	db.closer = r
+	return db, nil
+}
+
+// newRuntime creates a wazero runtime with WASI, and compiles SQLite in it. The WASI host is returned to release the
+// state of module instances closed before the runtime.
+func newRuntime(ctx context.Context, c *config) (wazero.Runtime, wazero.CompiledModule, *wasi, error) {
+	if err := c.validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Create a wazero runtime. The compilation cache is configured via the context passed here.
+	if c.compilationCacheDir != "" {
+		var err error
+		if ctx, err = experimental.WithCompilationCacheDirName(ctx, c.compilationCacheDir); err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to configure compilation cache: %w", err)
+		}
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, c.runtimeConfig)
+
+	// Initializes WASI (WebAssembly System Interface) environment.
+	w := newWASI(c)
+	if err := w.instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, nil, nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+
+	// Compile sqlite Wasm binary.
+	compiledSqlite, err := r.CompileModule(ctx, c.wasm, wazero.NewCompileConfig())
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, nil, nil, fmt.Errorf("failed to compile sqlite: %w", err)
+	}
+	return r, compiledSqlite, w, nil
+}
+
+// open instantiates a new SQLite module and opens the database in it. The returned DB closes only the module
+// instance.
+func open(ctx context.Context, r wazero.Runtime, compiledSqlite wazero.CompiledModule, c *config, mc wazero.ModuleConfig) (*DB, error) {
+	m, err := newSqliteModule(ctx, r, compiledSqlite, mc, c.abi, c.trapDump)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := m.openDB(ctx, c.dbName(), openReadWrite|openCreate)
+	if err != nil {
+		_ = m.mod.Close(ctx)
+		return nil, err
+	}
+
+	db := newDB(m, m.mod, handle, c)
+	if err = db.applyConfig(ctx, c); err != nil {
+		_ = db.Close(ctx)
+		return nil, err
+	}
+	return db, nil
+}
+
+// applyConfig applies the settings of c which are set per database.
+func (db *DB) applyConfig(ctx context.Context, c *config) error {
+	if c.sandbox {
+		if err := db.Exec(ctx, "PRAGMA trusted_schema = OFF"); err != nil {
+			return err
+		}
+	}
+	if c.busyTimeout > 0 {
+		return db.SetBusyTimeout(ctx, c.busyTimeout)
+	}
+	return nil
+}
+
+// SetBusyTimeout sets how long SQLite retries when the database is locked by another connection, via
+// sqlite3_busy_timeout. Zero or negative turns off the retries.
+func (db *DB) SetBusyTimeout(ctx context.Context, d time.Duration) error {
+	rc, err := db.m.callInt(ctx, db.m.busyTimeout, "sqlite3_busy_timeout", uint64(db.handle), api.EncodeI32(int32(d.Milliseconds())))
+	if err != nil {
+		return err
+	} else if rc != sqliteOK {
+		return db.error(ctx, rc, "")
+	}
+	return nil
+}
+
+// newDB returns the DB of the database handle opened in m, which releases closer on Close.
+func newDB(m *sqliteModule, closer api.Closer, handle uint32, c *config) *DB {
+	db := &DB{
+		closer:      closer,
+		m:           m,
+		handle:      handle,
+		invalidUTF8: c.invalidUTF8,
+		logger:      c.logger,
+		strictScan:  c.strictScan,
+		stmts:       map[uint32]string{},
+		dir:         c.mountedDir(),
+	}
+	if c.closeStats {
+		db.stats = newCloseStats()
+	}
+	return db
+}
+
+// Close finalizes the statements left open, closes the database via sqlite3_close, and releases the module instance
+// and therefore all the memory used by the database.
+func (db *DB) Close(ctx context.Context) error {
+	err := db.closeDB(ctx)
+	if closeErr := db.closer.Close(ctx); err == nil {
+		err = closeErr
+	}
+	if db.tempDir != "" {
+		if removeErr := os.RemoveAll(db.tempDir); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
+
+func (db *DB) closeDB(ctx context.Context) error {
+	if db.stats != nil {
+		db.logger.Printf("wazerosqlite: closing database: %s", db.stats.summary(db.m.mod.Memory().Size(ctx)))
+	}
+	for handle := range db.stmts {
+		// Finalize returns the error of the last step, if any, which doesn't matter at this point.
+		if _, err := db.m.callInt(ctx, db.m.finalize, "sqlite3_finalize", uint64(handle)); err != nil {
+			return err
+		}
+		delete(db.stmts, handle)
+	}
+
+	rc, err := db.m.callInt(ctx, db.m.closeDB, "sqlite3_close", uint64(db.handle))
+	if err != nil {
+		return err
+	} else if rc != sqliteOK {
+		return db.error(ctx, rc, "")
+	}
+	return nil
+}
+
+// error returns the Error of the result code rc with the message of the last failed call on the database.
+func (db *DB) error(ctx context.Context, rc int, sql string) error {
+	return newError(rc, db.m.errorMessage(ctx, db.handle), sql)
+}
+
+// Exec executes the query, which may consist of multiple statements, discarding any result rows.
+//
+// Exec returns ctx.Err() without executing the query if ctx is already done. Note that sqlite3_interrupt isn't
+// exported by the module, and a guest call can't be cancelled, so a query already running is not interrupted. Use
+// Query to be able to stop between rows.
+func (db *DB) Exec(ctx context.Context, query string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if db.stats == nil {
+		return db.m.execSql(ctx, db.handle, query)
+	}
+	db.stats.execs++
+	start := time.Now()
+	err := db.m.execSql(ctx, db.handle, query)
+	db.stats.record(query, time.Since(start), err)
+	return err
+}
+
+// Prepare compiles the query into a prepared statement.
+func (db *DB) Prepare(ctx context.Context, query string) (*Stmt, error) {
+	handle, err := db.m.prepareStmt(ctx, db.handle, query)
+	if err != nil {
+		db.stats.record(query, 0, err)
+		return nil, err
+	}
+	if db.stats != nil {
+		db.stats.prepared++
+	}
+	s := &Stmt{db: db, handle: handle, query: query}
+	db.stmts[handle] = query
+	runtime.SetFinalizer(s, reportLeakedStmt)
+	return s, nil
+}
@@ -0,0 +1,36 @@
+package wazerosqlite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithCompilationCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// The second Open loads the module compiled by the first one from the cache.
+	for i := 0; i < 2; i++ {
+		db, err := Open(ctx, WithCompilationCache(dir))
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if err = db.Exec(ctx, "CREATE TABLE t (a); INSERT INTO t VALUES (1)"); err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if err = db.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWithCompilationCacheNotDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(context.Background(), WithCompilationCache(file)); err == nil {
+		t.Error("Open succeeded with a file as the cache directory")
+	}
+}
@@ -0,0 +1,335 @@
+package wazerosqlite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DiffPages compares the database files at paths a and b page by page, and returns the 1-based numbers of the pages
+// which differ, including the pages which exist only in one of them.
+//
+// Both files must have the same page size. This is meant for verifying backups and debugging sync, where files are
+// expected to be byte-for-byte identical.
+func DiffPages(a, b string) ([]uint32, error) {
+	fa, ha, err := openDatabaseFile(a)
+	if err != nil {
+		return nil, err
+	}
+	defer fa.Close()
+
+	fb, hb, err := openDatabaseFile(b)
+	if err != nil {
+		return nil, err
+	}
+	defer fb.Close()
+
+	if ha.PageSize != hb.PageSize {
+		return nil, fmt.Errorf("page sizes differ: %d != %d", ha.PageSize, hb.PageSize)
+	}
+
+	var diff []uint32
+	pa, pb := make([]byte, ha.PageSize), make([]byte, hb.PageSize)
+	for pgno := uint32(1); ; pgno++ {
+		na, err := io.ReadFull(fa, pa)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		nb, err := io.ReadFull(fb, pb)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+
+		if na == 0 && nb == 0 {
+			return diff, nil
+		}
+		if !bytes.Equal(pa[:na], pb[:nb]) {
+			diff = append(diff, pgno)
+		}
+	}
+}
+
+// openDatabaseFile opens the database file at path and reads its header. The file is positioned at the beginning.
+func openDatabaseFile(path string) (*os.File, *Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b := make([]byte, headerSize)
+	if _, err = f.ReadAt(b, 0); err != nil {
+		f.Close()
+		if errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("%s: %w", path, ErrNotDatabase)
+		}
+		return nil, nil, err
+	}
+
+	h, err := ParseHeader(b)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, h, nil
+}
+
+// schemaObject is an entry of the schema table.
+type schemaObject struct {
+	typ, name, tblName, sql string
+}
+
+// DiffSQL returns the SQL statements which transform the content of the database a into b, in the spirit of the
+// sqldiff utility.
+//
+// Rows of tables with the same definition are matched by rowid, and produce INSERT, UPDATE or DELETE statements.
+// Tables whose definition differs, as well as WITHOUT ROWID tables with any difference, are dropped and recreated
+// with all their rows. Indexes, views and triggers are recreated when their definition differs.
+func DiffSQL(ctx context.Context, a, b *DB) ([]string, error) {
+	sa, err := a.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := b.schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dropping a table also drops its indexes and triggers, so they are changed as well.
+	changedTables := map[string]bool{}
+	for name, oa := range sa {
+		if ob, ok := sb[name]; oa.typ == "table" && (!ok || ob.typ != oa.typ || ob.sql != oa.sql) {
+			changedTables[name] = true
+		}
+	}
+	unchanged := func(name string) bool {
+		oa, okA := sa[name]
+		ob, okB := sb[name]
+		return okA && okB && oa.typ == ob.typ && oa.sql == ob.sql && !changedTables[oa.tblName]
+	}
+
+	var stmts []string
+	// Drop the objects which are removed or changed first, dependants before tables.
+	for _, typ := range []string{"trigger", "view", "index", "table"} {
+		for _, name := range sortedNames(sa) {
+			if sa[name].typ == typ && !unchanged(name) {
+				stmts = append(stmts, fmt.Sprintf("DROP %s IF EXISTS %s;", strings.ToUpper(typ), QuoteIdentifier(name)))
+			}
+		}
+	}
+
+	for _, typ := range []string{"table", "index", "view", "trigger"} {
+		for _, name := range sortedNames(sb) {
+			ob := sb[name]
+			if ob.typ != typ {
+				continue
+			}
+
+			if unchanged(name) {
+				if typ == "table" {
+					tableStmts, err := diffTable(ctx, a, b, ob)
+					if err != nil {
+						return nil, err
+					}
+					stmts = append(stmts, tableStmts...)
+				}
+				continue
+			}
+
+			stmts = append(stmts, ob.sql+";")
+			if typ == "table" {
+				inserts, err := dumpTable(ctx, b, name)
+				if err != nil {
+					return nil, err
+				}
+				stmts = append(stmts, inserts...)
+			}
+		}
+	}
+	return stmts, nil
+}
+
+// schema returns the user-defined objects in the schema table by name.
+func (db *DB) schema(ctx context.Context) (map[string]schemaObject, error) {
+	rows, err := db.Query(ctx, "SELECT type, name, tbl_name, sql FROM sqlite_master "+
+		"WHERE name NOT LIKE 'sqlite\\_%' ESCAPE '\\' AND sql IS NOT NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := map[string]schemaObject{}
+	for rows.Next() {
+		var o schemaObject
+		if err = rows.Scan(&o.typ, &o.name, &o.tblName, &o.sql); err != nil {
+			return nil, err
+		}
+		objects[o.name] = o
+	}
+	return objects, rows.Err()
+}
+
+// sortedNames returns the names of the objects in order.
+func sortedNames(objects map[string]schemaObject) []string {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// diffTable returns the statements to transform the rows of the table in a into those in b, which have the same
+// definition.
+func diffTable(ctx context.Context, a, b *DB, table schemaObject) ([]string, error) {
+	if strings.Contains(strings.ToUpper(table.sql), "WITHOUT ROWID") {
+		return diffWithoutRowidTable(ctx, a, b, table)
+	}
+
+	query := "SELECT rowid, * FROM " + QuoteIdentifier(table.name) + " ORDER BY rowid"
+	ra, err := a.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+	rb, err := b.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rb.Close()
+
+	columns, err := rb.Columns()
+	if err != nil {
+		return nil, err
+	}
+	columns = columns[1:]
+
+	var stmts []string
+	rowA, okA, err := nextRow(ra, len(columns)+1)
+	if err != nil {
+		return nil, err
+	}
+	rowB, okB, err := nextRow(rb, len(columns)+1)
+	if err != nil {
+		return nil, err
+	}
+	for okA || okB {
+		switch {
+		case okA && (!okB || rowA[0].Int64() < rowB[0].Int64()):
+			stmts = append(stmts, fmt.Sprintf("DELETE FROM %s WHERE rowid=%d;", QuoteIdentifier(table.name), rowA[0].Int64()))
+			rowA, okA, err = nextRow(ra, len(columns)+1)
+		case okB && (!okA || rowB[0].Int64() < rowA[0].Int64()):
+			stmts = append(stmts, insertStatement(table.name, append([]string{"rowid"}, columns...), rowB))
+			rowB, okB, err = nextRow(rb, len(columns)+1)
+		default:
+			var sets []string
+			for i, column := range columns {
+				if !valuesEqual(rowA[i+1], rowB[i+1]) {
+					sets = append(sets, QuoteIdentifier(column)+"="+QuoteLiteral(rowB[i+1]))
+				}
+			}
+			if len(sets) > 0 {
+				stmts = append(stmts, fmt.Sprintf("UPDATE %s SET %s WHERE rowid=%d;",
+					QuoteIdentifier(table.name), strings.Join(sets, ", "), rowB[0].Int64()))
+			}
+			if rowA, okA, err = nextRow(ra, len(columns)+1); err == nil {
+				rowB, okB, err = nextRow(rb, len(columns)+1)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stmts, nil
+}
+
+// diffWithoutRowidTable replaces all the rows of the WITHOUT ROWID table if its content differs between a and b.
+func diffWithoutRowidTable(ctx context.Context, a, b *DB, table schemaObject) ([]string, error) {
+	inserts, err := dumpTable(ctx, b, table.name)
+	if err != nil {
+		return nil, err
+	}
+	current, err := dumpTable(ctx, a, table.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Join(inserts, "\n") == strings.Join(current, "\n") {
+		return nil, nil
+	}
+	return append([]string{"DELETE FROM " + QuoteIdentifier(table.name) + ";"}, inserts...), nil
+}
+
+// dumpTable returns the INSERT statements for all the rows of the table.
+func dumpTable(ctx context.Context, db *DB, table string) ([]string, error) {
+	rows, err := db.Query(ctx, "SELECT * FROM "+QuoteIdentifier(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var stmts []string
+	for {
+		row, ok, err := nextRow(rows, len(columns))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return stmts, nil
+		}
+		stmts = append(stmts, insertStatement(table, columns, row))
+	}
+}
+
+// nextRow advances rows and returns the n columns of the row, or false if there are no more rows.
+func nextRow(rows *Rows, n int) ([]Value, bool, error) {
+	if !rows.Next() {
+		return nil, false, rows.Err()
+	}
+
+	row := make([]Value, n)
+	dest := make([]any, n)
+	for i := range row {
+		dest[i] = &row[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, false, err
+	}
+	return row, true, nil
+}
+
+// insertStatement returns the INSERT statement of the row into the table.
+func insertStatement(table string, columns []string, row []Value) string {
+	names := make([]string, len(columns))
+	values := make([]string, len(row))
+	for i := range columns {
+		names[i] = QuoteIdentifier(columns[i])
+		values[i] = QuoteLiteral(row[i])
+	}
+	return fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s);",
+		QuoteIdentifier(table), strings.Join(names, ","), strings.Join(values, ","))
+}
+
+// valuesEqual returns true if a and b have the same storage class and content, like the IS operator.
+func valuesEqual(a, b Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a.Type() {
+	case TypeInteger:
+		return a.Int64() == b.Int64()
+	case TypeFloat:
+		return a.Float64() == b.Float64()
+	case TypeNull:
+		return true
+	}
+	return bytes.Equal(a.Blob(), b.Blob())
+}
@@ -0,0 +1,174 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "insert",
+			a:    "CREATE TABLE t (a); INSERT INTO t VALUES (1)",
+			b:    "CREATE TABLE t (a); INSERT INTO t VALUES (1), ('x')",
+			want: []string{`INSERT INTO "t"("rowid","a") VALUES(2,'x');`},
+		},
+		{
+			name: "update",
+			a:    "CREATE TABLE t (a, b); INSERT INTO t VALUES (1, 'x'), (2, 'y')",
+			b:    "CREATE TABLE t (a, b); INSERT INTO t VALUES (1, 'x'), (2, NULL)",
+			want: []string{`UPDATE "t" SET "b"=NULL WHERE rowid=2;`},
+		},
+		{
+			name: "delete",
+			a:    "CREATE TABLE t (a); INSERT INTO t VALUES (1), (2), (3)",
+			b:    "CREATE TABLE t (a); INSERT INTO t VALUES (1), (2), (3); DELETE FROM t WHERE a = 2",
+			want: []string{`DELETE FROM "t" WHERE rowid=2;`},
+		},
+		{
+			name: "schema only",
+			a:    "CREATE TABLE t (a); INSERT INTO t VALUES (1)",
+			b:    "CREATE TABLE t (a); INSERT INTO t VALUES (1); CREATE INDEX t_a ON t (a); CREATE VIEW v AS SELECT a FROM t",
+			want: []string{"CREATE INDEX t_a ON t (a);", "CREATE VIEW v AS SELECT a FROM t;"},
+		},
+		{
+			name: "table definition",
+			a:    "CREATE TABLE t (a); CREATE INDEX t_a ON t (a); INSERT INTO t VALUES (1)",
+			b:    "CREATE TABLE t (a, b); CREATE INDEX t_a ON t (a); INSERT INTO t VALUES (1, 2)",
+			want: []string{
+				`DROP INDEX IF EXISTS "t_a";`,
+				`DROP TABLE IF EXISTS "t";`,
+				"CREATE TABLE t (a, b);",
+				`INSERT INTO "t"("a","b") VALUES(1,2);`,
+				"CREATE INDEX t_a ON t (a);",
+			},
+		},
+		{
+			name: "dropped table",
+			a:    "CREATE TABLE t (a); CREATE TABLE u (a)",
+			b:    "CREATE TABLE t (a)",
+			want: []string{`DROP TABLE IF EXISTS "u";`},
+		},
+		{
+			name: "without rowid",
+			a:    "CREATE TABLE t (k PRIMARY KEY, v) WITHOUT ROWID; INSERT INTO t VALUES ('a', 1)",
+			b:    "CREATE TABLE t (k PRIMARY KEY, v) WITHOUT ROWID; INSERT INTO t VALUES ('a', 2)",
+			want: []string{`DELETE FROM "t";`, `INSERT INTO "t"("k","v") VALUES('a',2);`},
+		},
+		{
+			name: "identical",
+			a:    "CREATE TABLE t (a); INSERT INTO t VALUES (1)",
+			b:    "CREATE TABLE t (a); INSERT INTO t VALUES (1)",
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := openWith(t, tt.a), openWith(t, tt.b)
+			stmts, err := DiffSQL(ctx, a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stmts, tt.want) {
+				t.Errorf("got %q, want %q", stmts, tt.want)
+			}
+
+			// Applying the statements to a makes it the same as b.
+			if err = a.Exec(ctx, strings.Join(stmts, "\n")); err != nil {
+				t.Fatal(err)
+			}
+			if stmts, err = DiffSQL(ctx, a, b); err != nil || len(stmts) != 0 {
+				t.Errorf("after applying the diff: %q, %v", stmts, err)
+			}
+		})
+	}
+}
+
+// openWith opens an in-memory database initialized with script.
+func openWith(t *testing.T, script string) *DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(ctx) })
+	if err = db.Exec(ctx, script); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestDiffPages(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	// create creates the database file name with script.
+	create := func(name, script string) {
+		t.Helper()
+		db, err := Open(ctx, WithFile(path(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close(ctx)
+		if err = db.Exec(ctx, script); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create("a.db", "CREATE TABLE t (a); INSERT INTO t VALUES (1)")
+	data, err := os.ReadFile(path("a.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path("b.db"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := DiffPages(path("a.db"), path("b.db")); err != nil || len(diff) != 0 {
+		t.Errorf("identical files: got %v, %v", diff, err)
+	}
+
+	// The header on page 1 changes on each write, and the new rows need new pages.
+	create("b.db", "CREATE TABLE u (b); INSERT INTO u SELECT zeroblob(10000)")
+	info, err := FileInfo(path("b.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := DiffPages(path("a.db"), path("b.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) < 2 || diff[0] != 1 || diff[len(diff)-1] != info.PageCount {
+		t.Errorf("got %v, want page 1 to %d", diff, info.PageCount)
+	}
+	// The order of the files doesn't matter.
+	if reversed, err := DiffPages(path("b.db"), path("a.db")); err != nil || !reflect.DeepEqual(reversed, diff) {
+		t.Errorf("reversed: got %v, %v, want %v", reversed, err, diff)
+	}
+
+	// The page size is a big-endian uint16 at offset 16 of the header.
+	other := append([]byte(nil), data...)
+	other[16], other[17] = byte(info.PageSize*2>>8), byte(info.PageSize*2)
+	if err = os.WriteFile(path("c.db"), other, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DiffPages(path("a.db"), path("c.db")); err == nil {
+		t.Error("compared files with different page sizes")
+	}
+
+	if err = os.WriteFile(path("text"), []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DiffPages(path("a.db"), path("text")); !errors.Is(err, ErrNotDatabase) {
+		t.Errorf("got %v, want ErrNotDatabase", err)
+	}
+}
@@ -0,0 +1,261 @@
+// Package driver implements database/sql/driver on top of wazerosqlite, and registers it as "wazero-sqlite".
+//
+//	db, err := sql.Open("wazero-sqlite", "path/to/file.db")
+//
+// Note: every connection opened by database/sql gets its own Wasm module instance. Since in-memory databases are not
+// shared between instances, use sql.DB.SetMaxOpenConns(1) with ":memory:" to make all the queries see the same
+// database.
+package driver
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"io"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// DriverName is the name the driver is registered as with database/sql.
+const DriverName = "wazero-sqlite"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver implements driver.Driver.
+type Driver struct {
+	// Options are passed to wazerosqlite.Open for each new connection.
+	Options []wazerosqlite.Option
+}
+
+// Open implements driver.Driver.
+//
+// The name is the path to the database file on the host. ":memory:" or empty opens a new in-memory database.
+func (d *Driver) Open(name string) (sqldriver.Conn, error) {
+	opts := d.Options
+	if name != "" && name != ":memory:" {
+		opts = append(opts[:len(opts):len(opts)], wazerosqlite.WithFile(name))
+	}
+
+	db, err := wazerosqlite.Open(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{db: db}, nil
+}
+
+// conn implements driver.Conn.
+type conn struct {
+	db *wazerosqlite.DB
+}
+
+// Prepare implements driver.Conn.
+func (c *conn) Prepare(query string) (sqldriver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *conn) PrepareContext(ctx context.Context, query string) (sqldriver.Stmt, error) {
+	s, err := c.db.Prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{s: s}, nil
+}
+
+// Close implements driver.Conn.
+func (c *conn) Close() error {
+	return c.db.Close(context.Background())
+}
+
+// Begin implements driver.Conn.
+func (c *conn) Begin() (sqldriver.Tx, error) {
+	return c.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *conn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	if opts.Isolation != sqldriver.IsolationLevel(sql.LevelDefault) &&
+		opts.Isolation != sqldriver.IsolationLevel(sql.LevelSerializable) {
+		return nil, fmt.Errorf("unsupported isolation level %d", opts.Isolation)
+	}
+
+	if err := c.db.Exec(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	if opts.ReadOnly {
+		if err := c.db.Exec(ctx, "PRAGMA query_only = ON"); err != nil {
+			_ = c.db.Exec(ctx, "ROLLBACK")
+			return nil, err
+		}
+	}
+	return &tx{c: c, readOnly: opts.ReadOnly}, nil
+}
+
+// tx implements driver.Tx.
+type tx struct {
+	c        *conn
+	readOnly bool
+}
+
+// Commit implements driver.Tx.
+func (t *tx) Commit() error {
+	return t.end("COMMIT")
+}
+
+// Rollback implements driver.Tx.
+func (t *tx) Rollback() error {
+	return t.end("ROLLBACK")
+}
+
+func (t *tx) end(query string) error {
+	ctx := context.Background()
+	err := t.c.db.Exec(ctx, query)
+	if t.readOnly {
+		if resetErr := t.c.db.Exec(ctx, "PRAGMA query_only = OFF"); err == nil {
+			err = resetErr
+		}
+	}
+	return err
+}
+
+// stmt implements driver.Stmt.
+type stmt struct {
+	s *wazerosqlite.Stmt
+}
+
+// Close implements driver.Stmt.
+func (s *stmt) Close() error {
+	return s.s.Close(context.Background())
+}
+
+// NumInput implements driver.Stmt.
+//
+// The number of parameters is unknown as sqlite3_bind_parameter_count is not exported by the Wasm build.
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+// Exec implements driver.Stmt.
+func (s *stmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *stmt) ExecContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	if err := s.bind(ctx, args); err != nil {
+		return nil, err
+	}
+
+	// Exec without arguments keeps the bindings made above.
+	res, err := s.s.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result{res}, nil
+}
+
+// result implements driver.Result.
+type result struct {
+	r wazerosqlite.Result
+}
+
+// LastInsertId implements driver.Result.
+func (r result) LastInsertId() (int64, error) {
+	return r.r.LastInsertID, nil
+}
+
+// RowsAffected implements driver.Result.
+func (r result) RowsAffected() (int64, error) {
+	return r.r.RowsAffected, nil
+}
+
+// Query implements driver.Stmt.
+func (s *stmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *stmt) QueryContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	if err := s.bind(ctx, args); err != nil {
+		return nil, err
+	}
+
+	n, err := s.s.ColumnCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, n)
+	for i := range columns {
+		if columns[i], err = s.s.ColumnName(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	return &rows{ctx: ctx, s: s.s, columns: columns}, nil
+}
+
+func (s *stmt) bind(ctx context.Context, args []sqldriver.NamedValue) error {
+	if err := s.s.Reset(ctx); err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		if arg.Name != "" {
+			if err := s.s.BindNamed(ctx, arg.Name, arg.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.s.Bind(ctx, arg.Ordinal, arg.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rows implements driver.Rows.
+type rows struct {
+	ctx     context.Context
+	s       *wazerosqlite.Stmt
+	columns []string
+}
+
+// Columns implements driver.Rows.
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+// Close implements driver.Rows.
+func (r *rows) Close() error {
+	return r.s.Reset(r.ctx)
+}
+
+// Next implements driver.Rows.
+func (r *rows) Next(dest []sqldriver.Value) error {
+	hasRow, err := r.s.Step(r.ctx)
+	if err != nil {
+		return err
+	} else if !hasRow {
+		return io.EOF
+	}
+
+	for i := range dest {
+		v, err := r.s.ColumnValue(r.ctx, i)
+		if err != nil {
+			return err
+		}
+		dest[i] = v.Any()
+	}
+	return nil
+}
+
+func valuesToNamedValues(args []sqldriver.Value) []sqldriver.NamedValue {
+	named := make([]sqldriver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = sqldriver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
@@ -0,0 +1,268 @@
+package driver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+	"wazero-sqlite/driver"
+)
+
+// openMemory opens an in-memory database with a single connection, so that all the queries see the same database.
+func openMemory(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(driver.DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY, name TEXT, score REAL, data BLOB)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExecQuery(t *testing.T) {
+	db := openMemory(t)
+
+	res, err := db.Exec("INSERT INTO t (name, score, data) VALUES (?, ?, ?)", "alice", 1.5, []byte{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := res.LastInsertId(); err != nil || id != 1 {
+		t.Errorf("LastInsertId = %d, %v", id, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		t.Errorf("RowsAffected = %d, %v", n, err)
+	}
+	if _, err = db.Exec("INSERT INTO t (name, score) VALUES (:name, :score)",
+		sql.Named("name", "bob"), sql.Named("score", 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query("SELECT id, name, score, data FROM t ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil || len(columns) != 4 || columns[1] != "name" {
+		t.Errorf("Columns = %v, %v", columns, err)
+	}
+
+	type row struct {
+		id    int64
+		name  string
+		score float64
+		data  []byte
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err = rows.Scan(&r.id, &r.name, &r.score, &r.data); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].name != "alice" || got[0].score != 1.5 || string(got[0].data) != "\x01\x02" ||
+		got[1].id != 2 || got[1].name != "bob" || got[1].score != 2 || got[1].data != nil {
+		t.Errorf("got %+v", got)
+	}
+
+	// Prepared statements can be executed several times.
+	stmt, err := db.Prepare("SELECT name FROM t WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for id, want := range map[int]string{1: "alice", 2: "bob"} {
+		var name string
+		if err = stmt.QueryRow(id).Scan(&name); err != nil || name != want {
+			t.Errorf("id %d: got %q, %v", id, name, err)
+		}
+	}
+	var name string
+	if err = stmt.QueryRow(3).Scan(&name); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing row: got %v", err)
+	}
+}
+
+func TestNullAndTime(t *testing.T) {
+	db := openMemory(t)
+
+	at := time.Date(2024, 3, 10, 12, 30, 45, 123_000_000, time.FixedZone("", 9*60*60))
+	if _, err := db.Exec("INSERT INTO t (name, score) VALUES (?, ?)", at, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var name sql.NullString
+	var score sql.NullFloat64
+	var data []byte
+	if err := db.QueryRow("SELECT name, score, data FROM t").Scan(&name, &score, &data); err != nil {
+		t.Fatal(err)
+	}
+	if !name.Valid || score.Valid || data != nil {
+		t.Errorf("got %v, %v, %v", name, score, data)
+	}
+	// time.Time is stored as text which SQLite's date and time functions understand.
+	if got, err := wazerosqlite.ParseTime(name.String); err != nil || !got.Equal(at) {
+		t.Errorf("stored time %q parses to %v, %v", name.String, got, err)
+	}
+	var unix int64
+	if err := db.QueryRow("SELECT unixepoch(name) FROM t").Scan(&unix); err == nil && unix != at.Unix() {
+		t.Errorf("unixepoch = %d, want %d", unix, at.Unix())
+	}
+	var utc string
+	if err := db.QueryRow("SELECT datetime(name) FROM t").Scan(&utc); err != nil || utc != "2024-03-10 03:30:45" {
+		t.Errorf("datetime = %q, %v", utc, err)
+	}
+}
+
+func TestTx(t *testing.T) {
+	db := openMemory(t)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("INSERT INTO t (name) VALUES ('committed')"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tx.Exec("INSERT INTO t (name) VALUES ('rolled back')"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	rows, err := db.Query("SELECT name FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if len(names) != 1 || names[0] != "committed" {
+		t.Errorf("got %v", names)
+	}
+
+	if _, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted}); err == nil {
+		t.Error("unsupported isolation level was accepted")
+	}
+}
+
+func TestReadOnlyTx(t *testing.T) {
+	db := openMemory(t)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err = tx.QueryRow("SELECT count(*) FROM t").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec("INSERT INTO t (name) VALUES ('x')")
+	if !errors.Is(err, wazerosqlite.CodeReadOnly) {
+		t.Errorf("write in a read-only transaction: got %v, want CodeReadOnly", err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection is writable again after the read-only transaction.
+	if _, err = db.Exec("INSERT INTO t (name) VALUES ('x')"); err != nil {
+		t.Errorf("write after a read-only transaction: %v", err)
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open(driver.DriverName, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []string{"CREATE TABLE t (a)", "INSERT INTO t VALUES (1)"} {
+		if _, err = conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A statement left open is finalized when the connection closes.
+	if _, err = conn.PrepareContext(ctx, "SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	if err = conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(ctx, "SELECT 1"); !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("Exec on a closed Conn: got %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Ping(); err == nil {
+		t.Error("Ping succeeded on a closed DB")
+	}
+
+	// The data is in the file.
+	if db, err = sql.Open(driver.DriverName, path); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var a int
+	if err = db.QueryRow("SELECT a FROM t").Scan(&a); err != nil || a != 1 {
+		t.Errorf("got %d, %v", a, err)
+	}
+}
+
+func TestNull(t *testing.T) {
+	db := openMemory(t)
+
+	at := time.Date(2024, 3, 10, 12, 30, 45, 0, time.UTC)
+	if _, err := db.Exec("INSERT INTO t (name, score, data) VALUES (?, ?, ?)",
+		wazerosqlite.Null[time.Time]{V: at, Valid: true}, wazerosqlite.Null[float64]{}, wazerosqlite.Null[[]byte]{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		name  wazerosqlite.Null[time.Time]
+		score wazerosqlite.Null[float64]
+		data  wazerosqlite.Null[[]byte]
+		id    wazerosqlite.Null[int]
+	)
+	if err := db.QueryRow("SELECT name, score, data, id FROM t").Scan(&name, &score, &data, &id); err != nil {
+		t.Fatal(err)
+	}
+	if !name.Valid || !name.V.Equal(at) || score.Valid || data.Valid || !id.Valid || id.V != 1 {
+		t.Errorf("got %v, %v, %v, %v", name, score, data, id)
+	}
+}
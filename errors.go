@@ -0,0 +1,276 @@
+package wazerosqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is a primary result code of SQLite. https://www.sqlite.org/rescode.html
+//
+// ErrorCode implements error so that it can be the target of errors.Is, e.g. errors.Is(err, CodeBusy).
+type ErrorCode int
+
+const (
+	// CodeError is SQLITE_ERROR.
+	CodeError ErrorCode = iota + 1
+	// CodeInternal is SQLITE_INTERNAL.
+	CodeInternal
+	// CodePerm is SQLITE_PERM.
+	CodePerm
+	// CodeAbort is SQLITE_ABORT.
+	CodeAbort
+	// CodeBusy is SQLITE_BUSY.
+	CodeBusy
+	// CodeLocked is SQLITE_LOCKED.
+	CodeLocked
+	// CodeNoMem is SQLITE_NOMEM.
+	CodeNoMem
+	// CodeReadOnly is SQLITE_READONLY.
+	CodeReadOnly
+	// CodeInterrupt is SQLITE_INTERRUPT.
+	CodeInterrupt
+	// CodeIOErr is SQLITE_IOERR.
+	CodeIOErr
+	// CodeCorrupt is SQLITE_CORRUPT.
+	CodeCorrupt
+	// CodeNotFound is SQLITE_NOTFOUND.
+	CodeNotFound
+	// CodeFull is SQLITE_FULL.
+	CodeFull
+	// CodeCantOpen is SQLITE_CANTOPEN.
+	CodeCantOpen
+	// CodeProtocol is SQLITE_PROTOCOL.
+	CodeProtocol
+	// CodeEmpty is SQLITE_EMPTY.
+	CodeEmpty
+	// CodeSchema is SQLITE_SCHEMA.
+	CodeSchema
+	// CodeTooBig is SQLITE_TOOBIG.
+	CodeTooBig
+	// CodeConstraint is SQLITE_CONSTRAINT.
+	CodeConstraint
+	// CodeMismatch is SQLITE_MISMATCH.
+	CodeMismatch
+	// CodeMisuse is SQLITE_MISUSE.
+	CodeMisuse
+	// CodeNoLFS is SQLITE_NOLFS.
+	CodeNoLFS
+	// CodeAuth is SQLITE_AUTH.
+	CodeAuth
+	// CodeFormat is SQLITE_FORMAT.
+	CodeFormat
+	// CodeRange is SQLITE_RANGE.
+	CodeRange
+	// CodeNotADB is SQLITE_NOTADB.
+	CodeNotADB
+	// CodeNotice is SQLITE_NOTICE.
+	CodeNotice
+	// CodeWarning is SQLITE_WARNING.
+	CodeWarning
+)
+
+// codeNames are the names of ErrorCode indexed by the code.
+var codeNames = [...]string{
+	CodeError:      "SQLITE_ERROR",
+	CodeInternal:   "SQLITE_INTERNAL",
+	CodePerm:       "SQLITE_PERM",
+	CodeAbort:      "SQLITE_ABORT",
+	CodeBusy:       "SQLITE_BUSY",
+	CodeLocked:     "SQLITE_LOCKED",
+	CodeNoMem:      "SQLITE_NOMEM",
+	CodeReadOnly:   "SQLITE_READONLY",
+	CodeInterrupt:  "SQLITE_INTERRUPT",
+	CodeIOErr:      "SQLITE_IOERR",
+	CodeCorrupt:    "SQLITE_CORRUPT",
+	CodeNotFound:   "SQLITE_NOTFOUND",
+	CodeFull:       "SQLITE_FULL",
+	CodeCantOpen:   "SQLITE_CANTOPEN",
+	CodeProtocol:   "SQLITE_PROTOCOL",
+	CodeEmpty:      "SQLITE_EMPTY",
+	CodeSchema:     "SQLITE_SCHEMA",
+	CodeTooBig:     "SQLITE_TOOBIG",
+	CodeConstraint: "SQLITE_CONSTRAINT",
+	CodeMismatch:   "SQLITE_MISMATCH",
+	CodeMisuse:     "SQLITE_MISUSE",
+	CodeNoLFS:      "SQLITE_NOLFS",
+	CodeAuth:       "SQLITE_AUTH",
+	CodeFormat:     "SQLITE_FORMAT",
+	CodeRange:      "SQLITE_RANGE",
+	CodeNotADB:     "SQLITE_NOTADB",
+	CodeNotice:     "SQLITE_NOTICE",
+	CodeWarning:    "SQLITE_WARNING",
+}
+
+// Error implements error.
+func (c ErrorCode) Error() string {
+	if c > 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return fmt.Sprintf("SQLITE_%d", int(c))
+}
+
+// Error is an error reported by SQLite.
+type Error struct {
+	// Code is the primary result code.
+	//
+	// Note: extended result codes aren't available, as the Wasm build doesn't export
+	// sqlite3_extended_result_codes.
+	Code ErrorCode
+	// Msg is the message of sqlite3_errmsg, or a description of the failed operation if it isn't available.
+	Msg string
+	// SQL is the statement which failed, if any.
+	SQL string
+}
+
+// newError returns the Error of the result code rc.
+func newError(rc int, msg, sql string) *Error {
+	return &Error{Code: ErrorCode(rc & 0xff), Msg: msg, SQL: sql}
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.SQL == "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s\nquery: %s", e.Code, e.Msg, e.SQL)
+}
+
+// Is returns true if target is the ErrorCode of e, so that errors.Is(err, CodeConstraint) works.
+func (e *Error) Is(target error) bool {
+	c, ok := target.(ErrorCode)
+	return ok && c == e.Code
+}
+
+// IsConstraintViolation returns true if err is a violation of a constraint, e.g. UNIQUE or NOT NULL.
+func IsConstraintViolation(err error) bool {
+	return errors.Is(err, CodeConstraint)
+}
+
+// ConstraintKind is the kind of a constraint.
+type ConstraintKind int
+
+const (
+	// ConstraintUnique is a UNIQUE or PRIMARY KEY constraint.
+	ConstraintUnique ConstraintKind = iota + 1
+	// ConstraintNotNull is a NOT NULL constraint.
+	ConstraintNotNull
+	// ConstraintForeignKey is a FOREIGN KEY constraint.
+	ConstraintForeignKey
+	// ConstraintCheck is a CHECK constraint.
+	ConstraintCheck
+)
+
+// constraintMessages are the prefixes of the messages of SQLite for each kind of constraint.
+var constraintMessages = []struct {
+	prefix string
+	kind   ConstraintKind
+}{
+	{"UNIQUE constraint failed", ConstraintUnique},
+	{"NOT NULL constraint failed", ConstraintNotNull},
+	{"FOREIGN KEY constraint failed", ConstraintForeignKey},
+	{"CHECK constraint failed", ConstraintCheck},
+}
+
+// ConstraintViolation is the constraint an error is about, as told by ConstraintOf.
+type ConstraintViolation struct {
+	// Kind is the kind of the constraint.
+	Kind ConstraintKind
+	// Table is the table of the constraint, if the message tells it, which it doesn't for FOREIGN KEY and CHECK.
+	Table string
+	// Columns are the columns of a UNIQUE or NOT NULL constraint, if the message tells them.
+	Columns []string
+	// Name is the name of a CHECK constraint, or of the index of a UNIQUE constraint on expressions.
+	Name string
+}
+
+// ConstraintOf returns the constraint err is a violation of, or false if it isn't a constraint violation or the
+// message is not one SQLite writes for the kinds of ConstraintKind.
+//
+// Note: extended result codes aren't available, so the constraint is parsed from the message, e.g.
+// "UNIQUE constraint failed: users.email".
+func ConstraintOf(err error) (*ConstraintViolation, bool) {
+	var e *Error
+	if !errors.As(err, &e) || e.Code != CodeConstraint {
+		return nil, false
+	}
+	for _, m := range constraintMessages {
+		if !strings.HasPrefix(e.Msg, m.prefix) {
+			continue
+		}
+		c := &ConstraintViolation{Kind: m.kind}
+		detail := strings.TrimPrefix(strings.TrimPrefix(e.Msg, m.prefix), ": ")
+		switch {
+		case detail == "":
+		case m.kind == ConstraintCheck:
+			c.Name = detail
+		case strings.HasPrefix(detail, "index '"):
+			c.Name = strings.TrimSuffix(strings.TrimPrefix(detail, "index '"), "'")
+		default:
+			// The columns are listed as "table.column, table.column".
+			for _, column := range strings.Split(detail, ", ") {
+				table, name, ok := strings.Cut(column, ".")
+				if !ok {
+					continue
+				}
+				c.Table = table
+				c.Columns = append(c.Columns, name)
+			}
+		}
+		return c, true
+	}
+	return nil, false
+}
+
+// IsUniqueViolation returns true if err is a violation of a UNIQUE or PRIMARY KEY constraint. See ConstraintOf for
+// the table and columns.
+func IsUniqueViolation(err error) bool {
+	return isConstraintKind(err, ConstraintUnique)
+}
+
+// IsNotNullViolation returns true if err is a violation of a NOT NULL constraint. See ConstraintOf for the table and
+// column.
+func IsNotNullViolation(err error) bool {
+	return isConstraintKind(err, ConstraintNotNull)
+}
+
+// IsForeignKeyViolation returns true if err is a violation of a FOREIGN KEY constraint, which are only enforced with
+// "PRAGMA foreign_keys = ON".
+func IsForeignKeyViolation(err error) bool {
+	return isConstraintKind(err, ConstraintForeignKey)
+}
+
+// IsCheckViolation returns true if err is a violation of a CHECK constraint. See ConstraintOf for its name.
+func IsCheckViolation(err error) bool {
+	return isConstraintKind(err, ConstraintCheck)
+}
+
+func isConstraintKind(err error, kind ConstraintKind) bool {
+	c, ok := ConstraintOf(err)
+	return ok && c.Kind == kind
+}
+
+// IsBusy returns true if err is caused by the database being locked by another connection.
+func IsBusy(err error) bool {
+	return errors.Is(err, CodeBusy) || errors.Is(err, CodeLocked)
+}
+
+// TrapError is returned when a call into the guest trapped, e.g. on an out-of-bounds memory access, or when a Conn
+// recovered a panic during a call. The state of the module instance is then unknown: a Conn keeps returning the same
+// TrapError, and its instance is closed and replaced on Release rather than returned to the pool.
+type TrapError struct {
+	// Err is the trap, or the recovered panic.
+	Err error
+	// Memory is a copy of the guest memory at the trap if WithTrapDump is set, e.g. to attach to a bug report.
+	Memory []byte
+}
+
+// Error implements error.
+func (e *TrapError) Error() string {
+	return "sqlite module instance trapped: " + e.Err.Error()
+}
+
+// Unwrap returns Err.
+func (e *TrapError) Unwrap() error {
+	return e.Err
+}
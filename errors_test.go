@@ -0,0 +1,69 @@
+package wazerosqlite
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestConstraintOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want *ConstraintViolation
+	}{
+		{
+			err:  newError(19, "UNIQUE constraint failed: users.email", ""),
+			want: &ConstraintViolation{Kind: ConstraintUnique, Table: "users", Columns: []string{"email"}},
+		},
+		{
+			err:  newError(19, "UNIQUE constraint failed: t.a, t.b", ""),
+			want: &ConstraintViolation{Kind: ConstraintUnique, Table: "t", Columns: []string{"a", "b"}},
+		},
+		{
+			err:  newError(19, "UNIQUE constraint failed: index 'lower_email'", ""),
+			want: &ConstraintViolation{Kind: ConstraintUnique, Name: "lower_email"},
+		},
+		{
+			err:  newError(19, "NOT NULL constraint failed: users.name", ""),
+			want: &ConstraintViolation{Kind: ConstraintNotNull, Table: "users", Columns: []string{"name"}},
+		},
+		{
+			err:  newError(19, "FOREIGN KEY constraint failed", ""),
+			want: &ConstraintViolation{Kind: ConstraintForeignKey},
+		},
+		{
+			err:  newError(19, "CHECK constraint failed: positive_price", ""),
+			want: &ConstraintViolation{Kind: ConstraintCheck, Name: "positive_price"},
+		},
+		{
+			// Wrapped errors are unwrapped.
+			err:  fmt.Errorf("failed to insert: %w", newError(19, "UNIQUE constraint failed: t.id", "INSERT ...")),
+			want: &ConstraintViolation{Kind: ConstraintUnique, Table: "t", Columns: []string{"id"}},
+		},
+		{err: newError(19, "something else", "")},
+		{err: newError(1, "UNIQUE constraint failed: t.id", "")},
+		{err: errors.New("UNIQUE constraint failed: t.id")},
+		{err: nil},
+	}
+	for _, tt := range tests {
+		got, ok := ConstraintOf(tt.err)
+		if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ConstraintOf(%v) = %+v, %v, want %+v", tt.err, got, ok, tt.want)
+		}
+	}
+}
+
+func TestIsViolation(t *testing.T) {
+	unique := newError(19, "UNIQUE constraint failed: t.id", "")
+	if !IsUniqueViolation(unique) || IsNotNullViolation(unique) || IsForeignKeyViolation(unique) ||
+		IsCheckViolation(unique) {
+		t.Error("UNIQUE violation isn't told apart")
+	}
+	if !IsForeignKeyViolation(newError(19, "FOREIGN KEY constraint failed", "")) {
+		t.Error("FOREIGN KEY violation isn't detected")
+	}
+	if !IsConstraintViolation(unique) {
+		t.Error("UNIQUE violation isn't a constraint violation")
+	}
+}
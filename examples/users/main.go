@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+type user struct {
+	id   int64
+	name string
+}
+
+func main() {
+	ctx := context.Background()
+
+	// Open the in-memory database.
+	db, err := wazerosqlite.Open(ctx)
+	if err != nil {
+		log.Panicln(err)
+	}
+	defer db.Close(ctx)
+
+	// Create table.
+	if err = db.Exec(ctx, `CREATE TABLE users (id int, name varchar(10))`); err != nil {
+		log.Panicln(err)
+	}
+
+	// Insert values.
+	if err = db.Exec(ctx, `INSERT INTO users(id, name) VALUES(0, 'go'), (1, 'zig'), (2, 'whatever')`); err != nil {
+		log.Panicln(err)
+	}
+
+	// Select users!
+	if err = forEachUser(ctx, db, func(u *user) {
+		fmt.Printf("user: id=%d, name='%s'\n", u.id, u.name)
+	}); err != nil {
+		log.Panicln(err)
+	}
+}
+
+// forEachUser calls fn for each user as the rows are stepped through, so that only one row is held in memory at a
+// time regardless of the number of users.
+func forEachUser(ctx context.Context, db *wazerosqlite.DB, fn func(u *user)) error {
+	rows, err := db.Query(ctx, "SELECT id, name FROM users")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var u user
+	for rows.Next() {
+		if err = rows.Scan(&u.id, &u.name); err != nil {
+			return err
+		}
+		fn(&u)
+	}
+	return rows.Err()
+}
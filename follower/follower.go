@@ -0,0 +1,438 @@
+// Package follower implements read-only replicas which follow a database snapshot published over HTTP, e.g. reference
+// data distributed via a CDN.
+//
+// A Follower periodically downloads the snapshot, which is a plain SQLite database file, and swaps it into a new
+// wazerosqlite.Pool once it is fully downloaded and validated. Readers always see a complete snapshot: those running
+// during a swap keep the previous one until they return.
+//
+//	f, err := follower.New(ctx, "https://cdn.example.com/ref.db", "/var/lib/ref")
+//	...
+//	defer f.Close(ctx)
+//	go f.Run(ctx)
+//	err = f.View(ctx, func(db *wazerosqlite.DB) error {
+//		rows, err := db.Query(ctx, "SELECT name FROM countries WHERE code = ?", "JP")
+//		...
+//	})
+package follower
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// ErrClosed is returned by View after Close.
+var ErrClosed = errors.New("follower is closed")
+
+// SchemaError is returned by Refresh when a new snapshot has a schema the SchemaHook rejected. The Follower keeps
+// serving the previous snapshot.
+type SchemaError struct {
+	// Old is the schema of the current snapshot.
+	Old string
+	// New is the schema of the rejected snapshot.
+	New string
+	// Err is the error returned by the SchemaHook.
+	Err error
+}
+
+// Error implements error.
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("schema of the snapshot changed from %s to %s: %v", e.Old, e.New, e.Err)
+}
+
+// Unwrap returns Err.
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaHook is called by Refresh with the DBs of the current and the new snapshot when their schemas differ, before
+// the new one is swapped in. old is nil for the first snapshot. The DBs must not be written to.
+//
+// The hook can check that the new schema is one the application supports, or prepare the application for it, e.g.
+// by migrating state derived from the database. If it returns an error, the new snapshot is discarded.
+type SchemaHook func(ctx context.Context, old, new *wazerosqlite.DB) error
+
+// Option configures New.
+type Option func(*Follower)
+
+// WithInterval sets how often Run polls for a new snapshot. Defaults to one minute.
+func WithInterval(d time.Duration) Option {
+	return func(f *Follower) {
+		f.interval = d
+	}
+}
+
+// WithHTTPClient sets the client used to download snapshots. Defaults to http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(f *Follower) {
+		f.client = c
+	}
+}
+
+// WithPoolSize sets the number of concurrent readers. Defaults to one.
+func WithPoolSize(n int) Option {
+	return func(f *Follower) {
+		f.poolSize = n
+	}
+}
+
+// WithSchemaHook sets the hook called when the schema of the snapshot changes. See SchemaHook.
+func WithSchemaHook(hook SchemaHook) Option {
+	return func(f *Follower) {
+		f.schemaHook = hook
+	}
+}
+
+// WithDBOptions sets the options every wazerosqlite.Pool is created with. WithFile is set by the Follower.
+func WithDBOptions(opts ...wazerosqlite.Option) Option {
+	return func(f *Follower) {
+		f.dbOpts = opts
+	}
+}
+
+// Follower is a read-only replica of a database snapshot published at a URL.
+type Follower struct {
+	// url is where the snapshot is downloaded from.
+	url string
+	// dir is the directory snapshots are stored in.
+	dir string
+	// interval is how often Run polls for a new snapshot.
+	interval time.Duration
+	// client is used to download snapshots.
+	client *http.Client
+	// poolSize is the size of the pool on each snapshot.
+	poolSize int
+	// dbOpts are passed to wazerosqlite.NewPool.
+	dbOpts []wazerosqlite.Option
+	// schemaHook is called when the schema changes, if set.
+	schemaHook SchemaHook
+
+	// refreshMu serializes Refresh.
+	refreshMu sync.Mutex
+	// etag is the ETag of the current snapshot, if the server sent one.
+	etag string
+	// schema is the schema hash of the current snapshot.
+	schema string
+
+	// statusMu guards status, separately from mu so that Status doesn't wait for a swap.
+	statusMu sync.Mutex
+	// status is returned by Status.
+	status Status
+
+	// mu guards the fields below. View holds the read lock for the duration of the callback, so that the pool isn't
+	// closed under it.
+	mu sync.RWMutex
+	// pool is the pool on the current snapshot.
+	pool *wazerosqlite.Pool
+	// path is the file of the current snapshot.
+	path string
+	// closed is true after Close.
+	closed bool
+}
+
+// New creates a Follower of the snapshot at url, which is stored in the directory dir, and downloads the first
+// snapshot.
+func New(ctx context.Context, url, dir string, opts ...Option) (*Follower, error) {
+	f := &Follower{url: url, dir: dir, interval: time.Minute, client: http.DefaultClient, poolSize: 1}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if _, err := f.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Run calls Refresh every interval until ctx is done or the Follower is closed. Errors of Refresh don't stop Run, and
+// the current snapshot stays in use until the next successful Refresh.
+func (f *Follower) Run(ctx context.Context) error {
+	t := time.NewTicker(f.interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			if f.isClosed() {
+				return ErrClosed
+			}
+			if _, err := f.Refresh(ctx); errors.Is(err, ErrClosed) {
+				return err
+			}
+		}
+	}
+}
+
+// isClosed returns true after Close.
+func (f *Follower) isClosed() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.closed
+}
+
+// Refresh downloads the snapshot if it has changed since the last time, and swaps it in. It returns true if the
+// snapshot was swapped.
+func (f *Follower) Refresh(ctx context.Context) (bool, error) {
+	f.refreshMu.Lock()
+	defer f.refreshMu.Unlock()
+
+	swapped, err := f.refresh(ctx)
+	f.statusMu.Lock()
+	if err != nil {
+		f.status.LastError = err
+		f.status.Failures++
+	} else {
+		f.status.CheckedAt, f.status.LastError, f.status.Failures = time.Now(), nil, 0
+	}
+	f.statusMu.Unlock()
+	return swapped, err
+}
+
+// refresh implements Refresh.
+func (f *Follower) refresh(ctx context.Context) (bool, error) {
+	// Don't download a snapshot only to find out that it can't be swapped in.
+	if f.isClosed() {
+		return false, ErrClosed
+	}
+	path, respHeader, err := f.download(ctx)
+	if err != nil || path == "" {
+		return false, err
+	}
+
+	header, err := wazerosqlite.FileInfo(path)
+	if err != nil {
+		os.Remove(path)
+		return false, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		os.Remove(path)
+		return false, err
+	}
+
+	opts := append(f.dbOpts[:len(f.dbOpts):len(f.dbOpts)], wazerosqlite.WithFile(path))
+	pool, err := wazerosqlite.NewPool(ctx, f.poolSize, opts...)
+	if err != nil {
+		os.Remove(path)
+		return false, err
+	}
+
+	schema, err := f.checkSchema(ctx, pool)
+	if err != nil {
+		_ = pool.Close(ctx)
+		os.Remove(path)
+		return false, err
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		_ = pool.Close(ctx)
+		os.Remove(path)
+		return false, ErrClosed
+	}
+	oldPool, oldPath := f.pool, f.path
+	f.pool, f.path, f.etag, f.schema = pool, path, respHeader.Get("ETag"), schema
+	f.mu.Unlock()
+
+	lastModified, _ := http.ParseTime(respHeader.Get("Last-Modified"))
+	f.statusMu.Lock()
+	f.status.ETag, f.status.Header, f.status.Size, f.status.Schema = f.etag, header, fi.Size(), schema
+	f.status.LastModified, f.status.SwappedAt = lastModified, time.Now()
+	f.statusMu.Unlock()
+
+	if oldPool != nil {
+		err = oldPool.Close(ctx)
+		os.Remove(oldPath)
+	}
+	return true, err
+}
+
+// checkSchema returns the schema hash of the new snapshot in pool, after calling the SchemaHook if it differs from the
+// current one.
+func (f *Follower) checkSchema(ctx context.Context, pool *wazerosqlite.Pool) (string, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+
+	schema, err := schemaHash(ctx, conn.DB)
+	if err != nil || schema == f.schema || f.schemaHook == nil {
+		return schema, err
+	}
+
+	// The schema is only written under refreshMu, which is held, but the pool is read under mu.
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return "", ErrClosed
+	}
+	var old *wazerosqlite.DB
+	if f.pool != nil {
+		oldConn, err := f.pool.Acquire(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer oldConn.Release()
+		old = oldConn.DB
+	}
+	if err = f.schemaHook(ctx, old, conn.DB); err != nil {
+		return "", &SchemaError{Old: f.schema, New: schema, Err: err}
+	}
+	return schema, nil
+}
+
+// schemaHash returns the hex-encoded SHA-256 of the definitions in sqlite_master, which identifies the schema
+// regardless of the data.
+func schemaHash(ctx context.Context, db *wazerosqlite.DB) (string, error) {
+	rows, err := db.Query(ctx, "SELECT type, name, tbl_name, sql FROM sqlite_master ORDER BY type, name")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	h := sha256.New()
+	for rows.Next() {
+		var typ, name, table string
+		var sql wazerosqlite.Null[string]
+		if err = rows.Scan(&typ, &name, &table, &sql); err != nil {
+			return "", err
+		}
+		// Each field is terminated with NUL, so that adjacent fields can't run into each other.
+		for _, s := range []string{typ, name, table, sql.V} {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// download fetches the snapshot into a new file in dir, and returns its path and the response header. The path is
+// empty if the snapshot hasn't changed.
+func (f *Follower) download(ctx context.Context) (string, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if f.etag != "" {
+		req.Header.Set("If-None-Match", f.etag)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return "", nil, nil
+	case http.StatusOK:
+	default:
+		return "", nil, fmt.Errorf("failed to download snapshot: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(f.dir, "snapshot-*.db")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err = io.Copy(tmp, resp.Body); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", nil, fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	return tmp.Name(), resp.Header, nil
+}
+
+// Status is the replication status of a Follower. A snapshot is replicated as a whole, so the position of the
+// follower is the snapshot it serves, identified by its ETag and the change counter in its header.
+type Status struct {
+	// ETag is the ETag of the current snapshot, or empty if the server didn't send one.
+	ETag string
+	// Header is the database header of the current snapshot.
+	Header *wazerosqlite.Header
+	// Size is the size of the current snapshot in bytes.
+	Size int64
+	// Schema is the SHA-256 of the schema of the current snapshot, in hex, which changes only with the schema.
+	Schema string
+	// LastModified is the Last-Modified time the server sent with the current snapshot, or zero if it didn't.
+	LastModified time.Time
+	// SwappedAt is when the current snapshot was swapped in.
+	SwappedAt time.Time
+	// CheckedAt is when the last successful Refresh confirmed the current snapshot is the latest one.
+	CheckedAt time.Time
+	// LastError is the error of the last Refresh, or nil if it succeeded.
+	LastError error
+	// Failures is the number of consecutive failed Refresh calls.
+	Failures int
+}
+
+// Lag returns how long the follower may be behind the primary, i.e. the time since the snapshot was last confirmed
+// to be the latest one. It grows while Refresh fails, which makes it suitable for alerting.
+func (s Status) Lag() time.Duration {
+	return time.Since(s.CheckedAt)
+}
+
+// Age returns the time since the primary last modified the current snapshot, or zero if the server didn't send
+// Last-Modified.
+func (s Status) Age() time.Duration {
+	if s.LastModified.IsZero() {
+		return 0
+	}
+	return time.Since(s.LastModified)
+}
+
+// Status returns the replication status, e.g. to export it as metrics or serve it on a health endpoint.
+func (f *Follower) Status() Status {
+	f.statusMu.Lock()
+	defer f.statusMu.Unlock()
+	return f.status
+}
+
+// View calls fn with a DB on the current snapshot. The DB must not be written to, nor used after fn returns.
+func (f *Follower) View(ctx context.Context, fn func(db *wazerosqlite.DB) error) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return ErrClosed
+	}
+
+	conn, err := f.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return fn(conn.DB)
+}
+
+// Close closes the current snapshot and removes its file. Run returns ErrClosed at its next poll.
+func (f *Follower) Close(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	err := f.pool.Close(ctx)
+	os.Remove(f.path)
+	return err
+}
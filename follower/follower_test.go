@@ -0,0 +1,297 @@
+package follower
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// snapshot returns a database file created by script.
+func snapshot(t *testing.T, script string) []byte {
+	t.Helper()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	db, err := wazerosqlite.Open(ctx, wazerosqlite.WithFile(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.ExecScript(ctx, script); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// server serves a snapshot with an ETag, which changes with each call to publish.
+type server struct {
+	mu sync.Mutex
+	// data is the snapshot, or nil to fail requests.
+	data []byte
+	// version is the ETag of data.
+	version int
+	// lastModified is sent with data.
+	lastModified time.Time
+	// requests counts the requests, and notModified the ones answered with 304.
+	requests, notModified int
+}
+
+func (s *server) publish(data []byte, lastModified time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data, s.lastModified = data, lastModified
+	s.version++
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.data == nil {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	etag := `"` + strconv.Itoa(s.version) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", s.lastModified.UTC().Format(http.TimeFormat))
+	w.Write(s.data)
+}
+
+// newServer starts a server with the first snapshot.
+func newServer(t *testing.T, data []byte) (*server, *httptest.Server) {
+	s := &server{}
+	s.publish(data, time.Now().Add(-time.Hour))
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+// assertView checks the value of the table t in the current snapshot of f.
+func assertView(t *testing.T, f *Follower, want string) {
+	t.Helper()
+	ctx := context.Background()
+	err := f.View(ctx, func(db *wazerosqlite.DB) error {
+		rows, err := db.Query(ctx, "SELECT a FROM t")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var got string
+		if !rows.Next() {
+			return rows.Err()
+		}
+		if err = rows.Scan(&got); err != nil {
+			return err
+		}
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// snapshotFiles returns the number of snapshot files in dir.
+func snapshotFiles(t *testing.T, dir string) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "snapshot-*.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, ts := newServer(t, snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('first')"))
+
+	f, err := New(ctx, ts.URL, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close(ctx)
+	assertView(t, f, "first")
+	status := f.Status()
+	if status.ETag != `"1"` || status.Header == nil || status.Size == 0 || status.Failures != 0 {
+		t.Errorf("got status %+v", status)
+	}
+
+	// An unchanged snapshot isn't downloaded again.
+	if swapped, err := f.Refresh(ctx); err != nil || swapped {
+		t.Errorf("got %t, %v for an unchanged snapshot", swapped, err)
+	}
+	if s.notModified != 1 {
+		t.Errorf("got %d responses 304, want 1", s.notModified)
+	}
+
+	// The second snapshot is swapped in, and the file of the first one is removed.
+	s.publish(snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('second')"), time.Now())
+	if swapped, err := f.Refresh(ctx); err != nil || !swapped {
+		t.Fatalf("got %t, %v for a new snapshot", swapped, err)
+	}
+	assertView(t, f, "second")
+	if n := snapshotFiles(t, dir); n != 1 {
+		t.Errorf("got %d snapshot files, want 1", n)
+	}
+	if got := f.Status().ETag; got != `"2"` {
+		t.Errorf("got ETag %s, want \"2\"", got)
+	}
+
+	// Invalid snapshots are discarded.
+	s.publish([]byte("not a database"), time.Now())
+	if _, err = f.Refresh(ctx); err == nil {
+		t.Error("swapped in an invalid snapshot")
+	}
+	assertView(t, f, "second")
+	if n := snapshotFiles(t, dir); n != 1 {
+		t.Errorf("got %d snapshot files, want 1", n)
+	}
+
+	if err = f.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.View(ctx, func(*wazerosqlite.DB) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("View after Close: got %v, want ErrClosed", err)
+	}
+	if n := snapshotFiles(t, dir); n != 0 {
+		t.Errorf("got %d snapshot files after Close", n)
+	}
+}
+
+func TestStatusLagAndAge(t *testing.T) {
+	ctx := context.Background()
+	s, ts := newServer(t, snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('first')"))
+
+	f, err := New(ctx, ts.URL, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close(ctx)
+
+	// The snapshot was modified an hour ago, and was just confirmed to be the latest one.
+	status := f.Status()
+	if age := status.Age(); age < time.Hour-time.Second || age > time.Hour+time.Minute {
+		t.Errorf("got age %s, want about an hour", age)
+	}
+	if lag := status.Lag(); lag < 0 || lag > time.Minute {
+		t.Errorf("got lag %s", lag)
+	}
+
+	// The lag grows while Refresh fails.
+	s.publish(nil, time.Time{})
+	time.Sleep(20 * time.Millisecond)
+	for i := 1; i <= 2; i++ {
+		if _, err = f.Refresh(ctx); err == nil {
+			t.Fatal("no error for an unavailable server")
+		}
+		if got := f.Status(); got.Failures != i || got.LastError == nil || got.CheckedAt != status.CheckedAt {
+			t.Errorf("got status %+v after %d failures", got, i)
+		}
+	}
+	if lag := f.Status().Lag(); lag < 20*time.Millisecond {
+		t.Errorf("got lag %s, want at least 20ms", lag)
+	}
+	assertView(t, f, "first")
+
+	// A successful Refresh resets them.
+	s.publish(snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('second')"), time.Now())
+	if _, err = f.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status = f.Status()
+	if status.Failures != 0 || status.LastError != nil || status.Lag() > time.Minute || status.Age() > time.Minute {
+		t.Errorf("got status %+v", status)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	s, ts := newServer(t, snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('first')"))
+
+	f, err := New(ctx, ts.URL, t.TempDir(), WithInterval(10*time.Millisecond), WithPoolSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	s.publish(snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('second')"), time.Now())
+	deadline := time.Now().Add(10 * time.Second)
+	for f.Status().ETag != `"2"` {
+		if time.Now().After(deadline) {
+			t.Fatal("Run didn't swap in the second snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertView(t, f, "second")
+
+	if err = f.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; !errors.Is(err, ErrClosed) {
+		t.Errorf("Run: got %v, want ErrClosed", err)
+	}
+}
+
+func TestSchemaHook(t *testing.T) {
+	ctx := context.Background()
+	s, ts := newServer(t, snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('first')"))
+
+	rejected := errors.New("rejected")
+	var calls int
+	hook := func(ctx context.Context, old, new *wazerosqlite.DB) error {
+		calls++
+		if calls == 1 && old != nil {
+			t.Error("got an old DB for the first snapshot")
+		}
+		if calls > 1 {
+			return rejected
+		}
+		return nil
+	}
+	f, err := New(ctx, ts.URL, t.TempDir(), WithSchemaHook(hook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close(ctx)
+	schema := f.Status().Schema
+
+	// The hook isn't called when only the data changes.
+	s.publish(snapshot(t, "CREATE TABLE t (a); INSERT INTO t VALUES ('second')"), time.Now())
+	if _, err = f.Refresh(ctx); err != nil || calls != 1 {
+		t.Fatalf("got %v, %d calls", err, calls)
+	}
+	if f.Status().Schema != schema {
+		t.Error("the schema hash changed with the data")
+	}
+
+	s.publish(snapshot(t, "CREATE TABLE t (a, b); INSERT INTO t VALUES ('third', 1)"), time.Now())
+	_, err = f.Refresh(ctx)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) || !errors.Is(err, rejected) || schemaErr.Old != schema {
+		t.Errorf("got %v, want a SchemaError", err)
+	}
+	assertView(t, f, "second")
+}
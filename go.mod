@@ -2,4 +2,4 @@ module wazero-sqlite
 
 go 1.18
 
-require github.com/tetratelabs/wazero v1.0.0-pre.1.0.20220906072906-ba1e4032f501 // indirect
+require github.com/tetratelabs/wazero v1.0.0-pre.1.0.20220906072906-ba1e4032f501
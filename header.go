@@ -0,0 +1,146 @@
+package wazerosqlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// headerSize is the size of the database header at the beginning of every SQLite database file.
+// See https://www.sqlite.org/fileformat.html#the_database_header
+const headerSize = 100
+
+// headerMagic is the header string every SQLite database file starts with.
+var headerMagic = []byte("SQLite format 3\x00")
+
+// ErrNotDatabase is returned when a file doesn't start with the SQLite database header.
+var ErrNotDatabase = errors.New("file is not a SQLite database")
+
+// TextEncoding is the text encoding of a database.
+type TextEncoding uint32
+
+const (
+	// TextEncodingUTF8 is UTF-8.
+	TextEncodingUTF8 TextEncoding = 1
+	// TextEncodingUTF16LE is UTF-16 little-endian.
+	TextEncodingUTF16LE TextEncoding = 2
+	// TextEncodingUTF16BE is UTF-16 big-endian.
+	TextEncodingUTF16BE TextEncoding = 3
+)
+
+// String implements fmt.Stringer.
+func (e TextEncoding) String() string {
+	switch e {
+	case TextEncodingUTF8:
+		return "UTF-8"
+	case TextEncodingUTF16LE:
+		return "UTF-16le"
+	case TextEncodingUTF16BE:
+		return "UTF-16be"
+	}
+	return fmt.Sprintf("TextEncoding(%d)", uint32(e))
+}
+
+// Header is the decoded database header of a SQLite database file.
+type Header struct {
+	// PageSize is the database page size in bytes.
+	PageSize int
+	// WriteVersion is the file format write version: 1 for legacy rollback journal, 2 for WAL.
+	WriteVersion uint8
+	// ReadVersion is the file format read version: 1 for legacy rollback journal, 2 for WAL.
+	ReadVersion uint8
+	// ReservedBytes is the number of bytes reserved at the end of each page, e.g. for checksums.
+	ReservedBytes uint8
+	// ChangeCounter is incremented on each transaction which modifies the file.
+	ChangeCounter uint32
+	// PageCount is the size of the database in pages.
+	PageCount uint32
+	// FreelistPages is the number of free pages.
+	FreelistPages uint32
+	// SchemaCookie is incremented on each schema change.
+	SchemaCookie uint32
+	// SchemaFormat is the schema format number, from 1 to 4.
+	SchemaFormat uint32
+	// TextEncoding is the encoding of all text in the database.
+	TextEncoding TextEncoding
+	// UserVersion is the value of PRAGMA user_version.
+	UserVersion uint32
+	// ApplicationID is the value of PRAGMA application_id.
+	ApplicationID uint32
+	// SQLiteVersion is the SQLITE_VERSION_NUMBER of the library which most recently modified the file.
+	SQLiteVersion uint32
+}
+
+// FileInfo reads the header of the SQLite database file at path without opening it as a database.
+func FileInfo(path string) (*Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := make([]byte, headerSize)
+	if _, err = io.ReadFull(f, b); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+			return nil, ErrNotDatabase
+		}
+		return nil, err
+	}
+	return ParseHeader(b)
+}
+
+// ParseHeader decodes the database header from b, which must hold at least the first 100 bytes of a database.
+func ParseHeader(b []byte) (*Header, error) {
+	if len(b) < headerSize || !bytes.HasPrefix(b, headerMagic) {
+		return nil, ErrNotDatabase
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(b[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	return &Header{
+		PageSize:      pageSize,
+		WriteVersion:  b[18],
+		ReadVersion:   b[19],
+		ReservedBytes: b[20],
+		ChangeCounter: binary.BigEndian.Uint32(b[24:28]),
+		PageCount:     binary.BigEndian.Uint32(b[28:32]),
+		FreelistPages: binary.BigEndian.Uint32(b[36:40]),
+		SchemaCookie:  binary.BigEndian.Uint32(b[40:44]),
+		SchemaFormat:  binary.BigEndian.Uint32(b[44:48]),
+		TextEncoding:  TextEncoding(binary.BigEndian.Uint32(b[56:60])),
+		UserVersion:   binary.BigEndian.Uint32(b[60:64]),
+		ApplicationID: binary.BigEndian.Uint32(b[68:72]),
+		SQLiteVersion: binary.BigEndian.Uint32(b[96:100]),
+	}, nil
+}
+
+// SetApplicationID sets the application ID stored in the database header, which lets tools identify files
+// belonging to an application. See https://www.sqlite.org/pragma.html#pragma_application_id
+func (db *DB) SetApplicationID(ctx context.Context, id uint32) error {
+	// The pragma takes a signed 32-bit integer.
+	return db.Exec(ctx, fmt.Sprintf("PRAGMA application_id = %d", int32(id)))
+}
+
+// ApplicationID returns the application ID stored in the database header.
+func (db *DB) ApplicationID(ctx context.Context) (uint32, error) {
+	rows, err := db.Query(ctx, "PRAGMA application_id")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var id int64
+	if rows.Next() {
+		err = rows.Scan(&id)
+	} else {
+		err = rows.Err()
+	}
+	return uint32(id), err
+}
@@ -0,0 +1,108 @@
+package wazerosqlite
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+)
+
+// QueryJSON executes the query and returns its rows as a JSON array of objects keyed by column name. See WriteJSON.
+func (db *DB) QueryJSON(ctx context.Context, query string, args ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := db.WriteJSON(ctx, &buf, query, args...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteJSON executes the query and streams its rows to w as a JSON array of objects keyed by column name, in the
+// order of the columns, e.g. to back an HTTP API without holding the result set in memory. args are bound like
+// DB.Query.
+//
+// INTEGER and REAL values are written as numbers, except infinities which are written as null, TEXT as strings, BLOB
+// as base64-encoded strings and NULL as null. If an error happens after the first row, w has received a truncated
+// array.
+func (db *DB) WriteJSON(ctx context.Context, w io.Writer, query string, args ...any) error {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	// The keys are encoded once, as they are the same for every row.
+	keys := make([][]byte, len(columns))
+	for i, c := range columns {
+		if keys[i], err = json.Marshal(c); err != nil {
+			return err
+		}
+	}
+
+	values := make([]Value, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteByte('[')
+	for n := 0; rows.Next(); n++ {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		if n > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				bw.WriteByte(',')
+			}
+			bw.Write(keys[i])
+			bw.WriteByte(':')
+			if err = writeJSONValue(bw, v); err != nil {
+				return err
+			}
+		}
+		bw.WriteByte('}')
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	bw.WriteByte(']')
+	return bw.Flush()
+}
+
+// writeJSONValue writes v as a JSON value.
+func writeJSONValue(bw *bufio.Writer, v Value) error {
+	var b []byte
+	var err error
+	switch v.Type() {
+	case TypeInteger:
+		b = strconv.AppendInt(nil, v.Int64(), 10)
+	case TypeFloat:
+		if f := v.Float64(); math.IsInf(f, 0) || math.IsNaN(f) {
+			b = []byte("null")
+		} else {
+			b = strconv.AppendFloat(nil, f, 'g', -1, 64)
+		}
+	case TypeText:
+		b, err = json.Marshal(v.Text())
+	case TypeBlob:
+		b, err = json.Marshal(v.Blob())
+	default:
+		b = []byte("null")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = bw.Write(b)
+	return err
+}
@@ -0,0 +1,64 @@
+package wazerosqlite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestQueryJSON(t *testing.T) {
+	ctx := context.Background()
+	db := openWith(t, `CREATE TABLE t (i, r, s, b, n);
+INSERT INTO t VALUES (1, 1.5, 'a "quoted"
+line', x'00ff41', NULL), (-9007199254740993, 1e300 * 1e300, '', x'', NULL)`)
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		want  string
+	}{
+		{
+			name:  "types",
+			query: "SELECT * FROM t ORDER BY rowid",
+			want: `[{"i":1,"r":1.5,"s":"a \"quoted\"\nline","b":"AP9B","n":null},` +
+				`{"i":-9007199254740993,"r":null,"s":"","b":"","n":null}]`,
+		},
+		{
+			name:  "column order and names",
+			query: `SELECT s AS "z", i AS "a""b" FROM t WHERE i = ?`,
+			args:  []any{1},
+			want:  `[{"z":"a \"quoted\"\nline","a\"b":1}]`,
+		},
+		{name: "empty", query: "SELECT * FROM t WHERE 0", want: `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.QueryJSON(ctx, tt.query, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("invalid JSON: %s", got)
+			}
+		})
+	}
+
+	// Blobs decode back to their bytes.
+	got, err := db.QueryJSON(ctx, "SELECT b FROM t WHERE i = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []struct{ B []byte }
+	if err = json.Unmarshal(got, &decoded); err != nil || !bytes.Equal(decoded[0].B, []byte{0, 0xff, 'A'}) {
+		t.Errorf("got %v, %v", decoded, err)
+	}
+
+	if _, err = db.QueryJSON(ctx, "SELECT * FROM missing"); err == nil {
+		t.Error("no error for an invalid query")
+	}
+}
@@ -0,0 +1,44 @@
+package wazerosqlite
+
+import "context"
+
+// MatchLike reports whether s matches the LIKE pattern with exactly the same semantics as SQLite, e.g. matching is
+// case-insensitive for ASCII characters only.
+//
+// Note: sqlite3_strlike is not exported by the Wasm build, so this evaluates the LIKE operator in a query instead.
+func (db *DB) MatchLike(ctx context.Context, pattern, s string) (bool, error) {
+	return db.match(ctx, "SELECT ? LIKE ?", pattern, s)
+}
+
+// MatchGlob reports whether s matches the GLOB pattern with exactly the same semantics as SQLite.
+//
+// Note: sqlite3_strglob is not exported by the Wasm build, so this evaluates the GLOB operator in a query instead.
+func (db *DB) MatchGlob(ctx context.Context, pattern, s string) (bool, error) {
+	return db.match(ctx, "SELECT ? GLOB ?", pattern, s)
+}
+
+func (db *DB) match(ctx context.Context, query, pattern, s string) (matched bool, err error) {
+	stmt, err := db.Prepare(ctx, query)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if closeErr := stmt.Close(ctx); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err = stmt.Bind(ctx, 1, s); err != nil {
+		return false, err
+	}
+	if err = stmt.Bind(ctx, 2, pattern); err != nil {
+		return false, err
+	}
+
+	if _, err = stmt.Step(ctx); err != nil {
+		return false, err
+	}
+
+	res, err := stmt.ColumnInt64(ctx, 0)
+	return res == 1, err
+}
@@ -0,0 +1,246 @@
+// Package migrate applies versioned SQL migrations to a wazerosqlite.DB, and records the applied versions in the
+// schema_migrations table of the database.
+//
+// Migrations are read from an fs.FS, typically embedded in the binary, as pairs of files named
+// "<version>_<name>.up.sql" and "<version>_<name>.down.sql", where version is a positive integer:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	sub, _ := fs.Sub(migrations, "migrations")
+//	m, err := migrate.New(sub)
+//	...
+//	applied, err := m.Up(ctx, db)
+//
+// Each migration runs in its own transaction together with the update of schema_migrations, so a failed migration
+// leaves the database at the previous version. For that reason, migrations must not contain BEGIN, COMMIT or
+// ROLLBACK themselves.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// ErrNoDown is returned by Down and DownTo when a migration to roll back has no down file.
+var ErrNoDown = errors.New("migration has no down file")
+
+// fileName matches the names of migration files.
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a versioned change to the schema.
+type Migration struct {
+	// Version orders the migrations, and identifies them in schema_migrations.
+	Version int64
+	// Name is the name in the file names, after the version.
+	Name string
+	// Up is the SQL which applies the migration.
+	Up string
+	// Down is the SQL which rolls the migration back, or empty if there is no down file.
+	Down string
+}
+
+// Migrator applies a set of migrations.
+type Migrator struct {
+	// migrations are sorted by version.
+	migrations []Migration
+}
+
+// New reads the migration files in the root directory of fsys. Other files are ignored. It is an error if two
+// migrations have the same version, or if a migration has a down file but no up file.
+func New(fsys fs.FS) (*Migrator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid version in %s", e.Name())
+		}
+		content, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	mr := &Migrator{}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		mr.migrations = append(mr.migrations, *m)
+	}
+	sort.Slice(mr.migrations, func(i, j int) bool { return mr.migrations[i].Version < mr.migrations[j].Version })
+	return mr, nil
+}
+
+// Migrations returns the migrations, sorted by version.
+func (mr *Migrator) Migrations() []Migration {
+	return mr.migrations
+}
+
+// Version returns the latest version applied to the database, or zero if none is.
+func (mr *Migrator) Version(ctx context.Context, db *wazerosqlite.DB) (int64, error) {
+	applied, err := mr.applied(ctx, db)
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1], nil
+}
+
+// Up applies all the migrations which haven't been applied yet, in order, and returns them.
+func (mr *Migrator) Up(ctx context.Context, db *wazerosqlite.DB) ([]Migration, error) {
+	return mr.UpTo(ctx, db, -1)
+}
+
+// UpTo is like Up, but only applies the migrations up to version. A negative version means all the migrations.
+//
+// On failure, the migrations applied before the failed one are returned with the error.
+func (mr *Migrator) UpTo(ctx context.Context, db *wazerosqlite.DB, version int64) ([]Migration, error) {
+	applied, err := mr.applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	isApplied := make(map[int64]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+
+	var done []Migration
+	for _, m := range mr.migrations {
+		if version >= 0 && m.Version > version {
+			break
+		} else if isApplied[m.Version] {
+			continue
+		}
+		err = mr.run(ctx, db, m.Up, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			m.Version, m.Name, time.Now().UTC())
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest applied migration, and returns it. It returns nil if no migration is applied.
+func (mr *Migrator) Down(ctx context.Context, db *wazerosqlite.DB) (*Migration, error) {
+	applied, err := mr.applied(ctx, db)
+	if err != nil || len(applied) == 0 {
+		return nil, err
+	}
+	done, err := mr.DownTo(ctx, db, applied[len(applied)-1]-1)
+	if len(done) == 0 {
+		return nil, err
+	}
+	return &done[0], err
+}
+
+// DownTo rolls back the applied migrations above version, from the latest one, and returns them. A version of zero
+// rolls back all the migrations.
+//
+// On failure, the migrations rolled back before the failed one are returned with the error.
+func (mr *Migrator) DownTo(ctx context.Context, db *wazerosqlite.DB, version int64) ([]Migration, error) {
+	applied, err := mr.applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(applied) - 1; i >= 0 && applied[i] > version; i-- {
+		m, ok := mr.find(applied[i])
+		if !ok {
+			return done, fmt.Errorf("applied migration %d is unknown", applied[i])
+		} else if m.Down == "" {
+			return done, fmt.Errorf("failed to roll back migration %d_%s: %w", m.Version, m.Name, ErrNoDown)
+		}
+		if err = mr.run(ctx, db, m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return done, fmt.Errorf("failed to roll back migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// run executes the script of a migration and the update of schema_migrations in a transaction.
+func (mr *Migrator) run(ctx context.Context, db *wazerosqlite.DB, script, update string, args ...any) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// ExecScript runs on the connection, so the statements belong to the transaction.
+	if _, err = db.ExecScript(ctx, script); err != nil {
+		return err
+	}
+	if _, err = db.ExecResult(ctx, update, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// applied returns the versions in schema_migrations in order, creating the table if it doesn't exist.
+func (mr *Migrator) applied(ctx context.Context, db *wazerosqlite.DB) ([]int64, error) {
+	err := db.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TEXT NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err = rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// find returns the migration of the version.
+func (mr *Migrator) find(version int64) (Migration, bool) {
+	i := sort.Search(len(mr.migrations), func(i int) bool { return mr.migrations[i].Version >= version })
+	if i < len(mr.migrations) && mr.migrations[i].Version == version {
+		return mr.migrations[i], true
+	}
+	return Migration{}, false
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	wazerosqlite "wazero-sqlite"
+)
+
+// migrations returns the files of migrations creating a table per version, at versions 1, 5 and 20.
+func migrations() fstest.MapFS {
+	return fstest.MapFS{
+		"1_a.up.sql":     {Data: []byte("CREATE TABLE a (x); INSERT INTO a VALUES (1);")},
+		"1_a.down.sql":   {Data: []byte("DROP TABLE a;")},
+		"5_b.up.sql":     {Data: []byte("CREATE TABLE b (x);")},
+		"5_b.down.sql":   {Data: []byte("DROP TABLE b;")},
+		"20_c.up.sql":    {Data: []byte("CREATE TABLE c (x);")},
+		"20_c.down.sql":  {Data: []byte("DROP TABLE c;")},
+		"README.md":      {Data: []byte("ignored")},
+		"sub/2_x.up.sql": {Data: []byte("ignored")},
+	}
+}
+
+func openDB(t *testing.T) *wazerosqlite.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := wazerosqlite.Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(ctx) })
+	return db
+}
+
+// assertState checks the tables of db, other than schema_migrations, and the versions in schema_migrations.
+func assertState(t *testing.T, db *wazerosqlite.DB, wantTables string, wantVersions string) {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := db.Query(ctx, `SELECT
+  (SELECT coalesce(group_concat(name), '') FROM (SELECT name FROM sqlite_master
+    WHERE type = 'table' AND name != 'schema_migrations' ORDER BY name)),
+  (SELECT coalesce(group_concat(version), '') FROM (SELECT version FROM schema_migrations ORDER BY version))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables, versions string
+	if !rows.Next() || rows.Scan(&tables, &versions) != nil {
+		t.Fatal(rows.Err())
+	}
+	if tables != wantTables || versions != wantVersions {
+		t.Errorf("got tables %q and versions %q, want %q and %q", tables, versions, wantTables, wantVersions)
+	}
+}
+
+func versions(ms []Migration) []int64 {
+	var vs []int64
+	for _, m := range ms {
+		vs = append(vs, m.Version)
+	}
+	return vs
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mr, err := New(migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(mr.Migrations()); !reflect.DeepEqual(got, []int64{1, 5, 20}) {
+		t.Errorf("got migrations %v", got)
+	}
+	if v, err := mr.Version(ctx, db); err != nil || v != 0 {
+		t.Errorf("got version %d, %v on an empty database", v, err)
+	}
+
+	applied, err := mr.Up(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(applied); !reflect.DeepEqual(got, []int64{1, 5, 20}) {
+		t.Errorf("applied %v", got)
+	}
+	assertState(t, db, "a,b,c", "1,5,20")
+	if v, err := mr.Version(ctx, db); err != nil || v != 20 {
+		t.Errorf("got version %d, %v", v, err)
+	}
+
+	// Running Up again is a no-op.
+	if applied, err = mr.Up(ctx, db); err != nil || len(applied) != 0 {
+		t.Errorf("applied %v, %v again", versions(applied), err)
+	}
+	assertState(t, db, "a,b,c", "1,5,20")
+}
+
+func TestUpToAndGaps(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mr, err := New(migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The version between two migrations applies the ones below it.
+	applied, err := mr.UpTo(ctx, db, 10)
+	if err != nil || !reflect.DeepEqual(versions(applied), []int64{1, 5}) {
+		t.Fatalf("applied %v, %v", versions(applied), err)
+	}
+	assertState(t, db, "a,b", "1,5")
+
+	// A migration added in a gap below the latest applied version is applied by the next Up.
+	fsys := migrations()
+	fsys["3_d.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE d (x);")}
+	if mr, err = New(fsys); err != nil {
+		t.Fatal(err)
+	}
+	applied, err = mr.Up(ctx, db)
+	if err != nil || !reflect.DeepEqual(versions(applied), []int64{3, 20}) {
+		t.Fatalf("applied %v, %v", versions(applied), err)
+	}
+	assertState(t, db, "a,b,c,d", "1,3,5,20")
+
+	// It has no down file, so rolling back stops at it.
+	rolledBack, err := mr.DownTo(ctx, db, 0)
+	if !errors.Is(err, ErrNoDown) || !reflect.DeepEqual(versions(rolledBack), []int64{20, 5}) {
+		t.Errorf("rolled back %v, %v, want ErrNoDown", versions(rolledBack), err)
+	}
+	assertState(t, db, "a,d", "1,3")
+}
+
+func TestDown(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mr, err := New(migrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = mr.Up(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := mr.Down(ctx, db)
+	if err != nil || m == nil || m.Version != 20 {
+		t.Fatalf("rolled back %v, %v", m, err)
+	}
+	assertState(t, db, "a,b", "1,5")
+
+	// The version between two migrations rolls back the ones above it.
+	rolledBack, err := mr.DownTo(ctx, db, 3)
+	if err != nil || !reflect.DeepEqual(versions(rolledBack), []int64{5}) {
+		t.Fatalf("rolled back %v, %v", versions(rolledBack), err)
+	}
+	assertState(t, db, "a", "1")
+
+	if rolledBack, err = mr.DownTo(ctx, db, 0); err != nil || !reflect.DeepEqual(versions(rolledBack), []int64{1}) {
+		t.Fatalf("rolled back %v, %v", versions(rolledBack), err)
+	}
+	assertState(t, db, "", "")
+	if m, err = mr.Down(ctx, db); err != nil || m != nil {
+		t.Errorf("rolled back %v, %v with no migration applied", m, err)
+	}
+}
+
+func TestUpFailure(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := migrations()
+	// The first statement succeeds, and is rolled back with the migration.
+	fsys["5_b.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE b (x); INSERT INTO missing VALUES (1);")}
+	mr, err := New(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := mr.Up(ctx, db)
+	if err == nil || !strings.Contains(err.Error(), "5_b") {
+		t.Errorf("got %v, want the failure of 5_b", err)
+	}
+	if !reflect.DeepEqual(versions(applied), []int64{1}) {
+		t.Errorf("applied %v", versions(applied))
+	}
+	assertState(t, db, "a", "1")
+
+	// Running it again fails the same way, without applying anything.
+	if applied, err = mr.Up(ctx, db); err == nil || len(applied) != 0 {
+		t.Errorf("applied %v, %v", versions(applied), err)
+	}
+	assertState(t, db, "a", "1")
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{name: "duplicate version", files: []string{"1_a.up.sql", "1_b.up.sql"}},
+		{name: "down without up", files: []string{"1_a.up.sql", "2_b.down.sql"}},
+		{name: "zero version", files: []string{"0_a.up.sql"}},
+		{name: "version overflow", files: []string{"99999999999999999999_a.up.sql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, f := range tt.files {
+				fsys[f] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			}
+			if _, err := New(fsys); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// sqliteModule corresponds to a Wasm module instance used to execute queries against the in-Wasm-memory db.
+//
+// The functions which only take and return numbers have the same signature in all the builds of SQLite, so they are
+// called directly. The others pass strings or return results through memory, which depends on the build, and are
+// implemented by the embedded abi.
+type sqliteModule struct {
+	abi
+
+	// mod is the underlying module instance.
+	mod api.Module
+	// memory holds the memory instance of this module.
+	memory api.Memory
+	// step holds the function for "sqlite3_step" in SQLite C interface.
+	step api.Function
+	// columnInt holds the function for "sqlite3_column_int64" in SQLite C interface.
+	columnInt api.Function
+	// columnDouble holds the function for "sqlite3_column_double" in SQLite C interface.
+	columnDouble api.Function
+	// columnCount holds the function for "sqlite3_column_count" in SQLite C interface.
+	columnCount api.Function
+	// columnType holds the function for "sqlite3_column_type" in SQLite C interface.
+	columnType api.Function
+	// bindInt holds the function for "sqlite3_bind_int64" in SQLite C interface.
+	bindInt api.Function
+	// bindDouble holds the function for "sqlite3_bind_double" in SQLite C interface.
+	bindDouble api.Function
+	// bindText holds the function for "sqlite3_bind_text" in SQLite C interface.
+	bindText api.Function
+	// bindBlob holds the function for "sqlite3_bind_blob" in SQLite C interface.
+	bindBlob api.Function
+	// bindNull holds the function for "sqlite3_bind_null" in SQLite C interface.
+	bindNull api.Function
+	// reset holds the function for "sqlite3_reset" in SQLite C interface.
+	reset api.Function
+	// finalize holds the function for "sqlite3_finalize" in SQLite C interface.
+	finalize api.Function
+	// closeDB holds the function for "sqlite3_close" in SQLite C interface.
+	closeDB api.Function
+	// busyTimeout holds the function for "sqlite3_busy_timeout" in SQLite C interface.
+	busyTimeout api.Function
+	// changes holds the function for "sqlite3_changes" in SQLite C interface.
+	changes api.Function
+	// totalChanges holds the function for "sqlite3_total_changes" in SQLite C interface.
+	totalChanges api.Function
+	// trap is the first trap of a call into the module, after which the state of the instance is unknown.
+	trap *TrapError
+	// dumpOnTrap is true to copy the memory into trap.
+	dumpOnTrap bool
+}
+
+// abi implements the calls whose calling convention differs between the builds of SQLite.
+type abi interface {
+	// openDB opens the database `name` and returns its handle.
+	openDB(ctx context.Context, name string, flags uint32) (uint32, error)
+	// prepareStmt compiles the query into a prepared statement and returns its handle.
+	prepareStmt(ctx context.Context, dbHandle uint32, query string) (uint32, error)
+	// execSql executes the query via sqlite3_exec, discarding any rows.
+	execSql(ctx context.Context, dbHandle uint32, query string) error
+	// readText tries to read the text column in the stmt.
+	readText(ctx context.Context, stmt uint32, columnIndex uint32) (string, error)
+	// readBlob tries to read the blob column in the stmt. The returned slice is a copy of the guest memory.
+	readBlob(ctx context.Context, stmt uint32, columnIndex uint32) ([]byte, error)
+	// readColumnName returns the name of the column in the result set of the stmt.
+	readColumnName(ctx context.Context, stmt uint32, columnIndex uint32) (string, error)
+	// errorMessage returns sqlite3_errmsg of the db, or a placeholder if it can't be read.
+	errorMessage(ctx context.Context, dbHandle uint32) string
+	// bindBytes binds a copy of b to the parameter at index with either "sqlite3_bind_text" or "sqlite3_bind_blob".
+	bindBytes(ctx context.Context, f api.Function, name string, stmt uint32, index int, b []byte) (int, error)
+}
+
+// ABI is the calling convention of a SQLite Wasm binary.
+type ABI int
+
+const (
+	// ABIFluence is the convention of the fluencelabs build embedded in this package, which returns results through
+	// "get_result_ptr" and "get_result_size".
+	ABIFluence ABI = iota
+	// ABIWasiSDK is the standard C ABI of SQLite compiled with wasi-sdk, which must export "malloc" and "free" along
+	// with the SQLite C interface.
+	ABIWasiSDK
+)
+
+// newSqliteModule instantiates compiledSqlite in the given wazero.Runtime `r`.
+func newSqliteModule(ctx context.Context, r wazero.Runtime, compiledSqlite wazero.CompiledModule, config wazero.ModuleConfig, a ABI, dumpOnTrap bool) (*sqliteModule, error) {
+	sqlite, err := r.InstantiateModule(ctx, compiledSqlite, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate sqlite module: %w", err)
+	}
+
+	m := &sqliteModule{mod: sqlite, memory: sqlite.Memory(), dumpOnTrap: dumpOnTrap}
+	m.step = m.export("sqlite3_step")
+	m.columnInt = m.export("sqlite3_column_int64")
+	m.columnDouble = m.export("sqlite3_column_double")
+	m.columnCount = m.export("sqlite3_column_count")
+	m.columnType = m.export("sqlite3_column_type")
+	m.bindInt = m.export("sqlite3_bind_int64")
+	m.bindDouble = m.export("sqlite3_bind_double")
+	m.bindText = m.export("sqlite3_bind_text")
+	m.bindBlob = m.export("sqlite3_bind_blob")
+	m.bindNull = m.export("sqlite3_bind_null")
+	m.reset = m.export("sqlite3_reset")
+	m.finalize = m.export("sqlite3_finalize")
+	m.closeDB = m.export("sqlite3_close")
+	m.busyTimeout = m.export("sqlite3_busy_timeout")
+	m.changes = m.export("sqlite3_changes")
+	m.totalChanges = m.export("sqlite3_total_changes")
+
+	switch a {
+	case ABIFluence:
+		m.abi = newFluenceABI(m)
+	case ABIWasiSDK:
+		m.abi = newWasiSDKABI(m)
+	default:
+		_ = sqlite.Close(ctx)
+		return nil, fmt.Errorf("unknown ABI %d", a)
+	}
+	return m, nil
+}
+
+// export returns the exported function `name`, whose calls record their traps in s.trap, or nil if there is none.
+func (s *sqliteModule) export(name string) api.Function {
+	f := s.mod.ExportedFunction(name)
+	if f == nil {
+		return nil
+	}
+	return trapFunction{Function: f, m: s}
+}
+
+// trapFunction is an exported function of the module which records traps in the module.
+type trapFunction struct {
+	api.Function
+	// m is the module the function is exported by.
+	m *sqliteModule
+}
+
+// Call implements api.Function.
+func (f trapFunction) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
+	res, err := f.Function.Call(ctx, params...)
+	if err != nil && isTrap(err) {
+		f.m.setTrap(ctx, err)
+	}
+	return res, err
+}
+
+// isTrap returns true if err is a trap of the guest, e.g. an out-of-bounds memory access, a panic of a host function
+// or the exit of the guest, rather than an error returned by SQLite.
+//
+// Note: this version of wazero doesn't export the type of traps, but always appends the Wasm stack trace to them.
+func isTrap(err error) bool {
+	var exitErr *sys.ExitError
+	return errors.As(err, &exitErr) || strings.Contains(err.Error(), "\nwasm stack trace:")
+}
+
+// setTrap records err as the trap of the module, unless one is recorded already.
+func (s *sqliteModule) setTrap(ctx context.Context, err error) {
+	if s.trap != nil {
+		return
+	}
+	s.trap = &TrapError{Err: err}
+	if s.dumpOnTrap {
+		if b, ok := s.memory.Read(ctx, 0, s.memory.Size(ctx)); ok {
+			s.trap.Memory = append([]byte(nil), b...)
+		}
+	}
+}
+
+// execStep advances the stmt and returns the raw result code.
+func (s *sqliteModule) execStep(ctx context.Context, stmt uint32) (int, error) {
+	res, err := s.step.Call(ctx, uint64(stmt))
+	if err != nil {
+		return 0, fmt.Errorf("failed to call step: %w", err)
+	}
+	return int(res[0]), nil
+}
+
+// readInt tries to read the integer column in the stmt.
+//
+// Note: sqlite3_column_int64 returns the full 64-bit value, so this must not be narrowed to int which is 32-bit on
+// some platforms.
+func (s *sqliteModule) readInt(ctx context.Context, stmt uint32, columnIndex uint32) (int64, error) {
+	res, err := s.columnInt.Call(ctx, uint64(stmt), uint64(columnIndex))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %d-th column as integer: %w", columnIndex, err)
+	}
+	return int64(res[0]), nil
+}
+
+// readDouble tries to read the floating point column in the stmt.
+//
+// Note: f64 values are passed through wazero as their IEEE 754 bit pattern in uint64.
+func (s *sqliteModule) readDouble(ctx context.Context, stmt uint32, columnIndex uint32) (float64, error) {
+	res, err := s.columnDouble.Call(ctx, uint64(stmt), uint64(columnIndex))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %d-th column as double: %w", columnIndex, err)
+	}
+	return api.DecodeF64(res[0]), nil
+}
+
+// callInt calls the function `f` which directly returns an int as its result, e.g. the result code or column count.
+func (s *sqliteModule) callInt(ctx context.Context, f api.Function, name string, params ...uint64) (int, error) {
+	res, err := f.Call(ctx, params...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call %s: %w", name, err)
+	}
+	return int(int32(res[0])), nil
+}
@@ -0,0 +1,261 @@
+package wazerosqlite
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Option configures Open.
+type Option func(*config)
+
+// config holds the settings applied by Option.
+type config struct {
+	// runtimeConfig is used to create the wazero runtime.
+	runtimeConfig wazero.RuntimeConfig
+	// invalidUTF8 is how invalid UTF-8 text is handled.
+	invalidUTF8 InvalidUTF8Mode
+	// sysClock is true to give the guest access to the host's clocks.
+	sysClock bool
+	// file is the host path of the database file, or empty for an in-memory database.
+	file string
+	// dir is the host directory mounted in the guest if file is empty.
+	dir string
+	// logger is where problems like leaked statements are reported.
+	logger *log.Logger
+	// compilationCacheDir is the directory the compiled module is cached in, or empty not to cache it.
+	compilationCacheDir string
+	// wasm is the SQLite Wasm binary.
+	wasm []byte
+	// abi is the calling convention of wasm.
+	abi ABI
+	// busyTimeout is how long to wait for a locked database, or zero not to wait.
+	busyTimeout time.Duration
+	// sandbox is true to restrict the guest to in-memory databases.
+	sandbox bool
+	// strictScan is true to fail scans whose destination doesn't match the storage class of the column.
+	strictScan bool
+	// closeStats is true to log statistics when a DB is closed.
+	closeStats bool
+	// trapDump is true to copy the guest memory into TrapError.
+	trapDump bool
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{runtimeConfig: wazero.NewRuntimeConfig(), logger: log.Default(), wasm: sqlite3Wasm}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithRuntimeConfig sets the wazero.RuntimeConfig used to create the runtime, e.g. to choose the interpreter on
+// platforms the compiler doesn't support. Defaults to wazero.NewRuntimeConfig.
+func WithRuntimeConfig(rc wazero.RuntimeConfig) Option {
+	return func(c *config) {
+		c.runtimeConfig = rc
+	}
+}
+
+// WithLogger sets the logger problems are reported to, e.g. statements garbage collected without Stmt.Close.
+// Defaults to log.Default.
+func WithLogger(l *log.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// WithCompilationCache persists the compiled SQLite module in dir, so that following Opens, including those in other
+// processes, skip the compilation which dominates the startup time.
+//
+// Note: the cache is only used by the compiler, not the interpreter.
+func WithCompilationCache(dir string) Option {
+	return func(c *config) {
+		c.compilationCacheDir = dir
+	}
+}
+
+// WithWasm runs the SQLite Wasm binary with the calling convention abi instead of the fluencelabs build embedded in
+// this package, e.g. to use a newer version of SQLite compiled with wasi-sdk.
+//
+// A binary for ABIWasiSDK built as a WASI reactor is initialized via its "_initialize" export. It must be compiled
+// with a VFS which works without file locking, such as SQLITE_OS_OTHER with a custom VFS or "unix-none".
+func WithWasm(bin []byte, abi ABI) Option {
+	return func(c *config) {
+		c.wasm, c.abi = bin, abi
+	}
+}
+
+// WithBusyTimeout makes SQLite retry for up to d when the database is locked by another connection, instead of
+// failing with CodeBusy right away. It also bounds how long Pool retries statements failing with CodeBusy or
+// CodeLocked. See DB.SetBusyTimeout.
+func WithBusyTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.busyTimeout = d
+	}
+}
+
+// WithSystemClock gives SQLite access to the host's real clocks.
+//
+// By default, wazero gives the guest a fake clock for determinism, so "now" in SQLite's date and time functions
+// doesn't reflect the actual time.
+func WithSystemClock() Option {
+	return func(c *config) {
+		c.sysClock = true
+	}
+}
+
+// WithFile opens the database file at path on the host instead of an in-memory database, so that the data survives
+// process restarts. The file is created if it doesn't exist.
+//
+// The directory containing the file is mounted in the guest via WASI, so that SQLite can also create its journal
+// next to the database.
+func WithFile(path string) Option {
+	return func(c *config) {
+		c.file = path
+	}
+}
+
+// WithDir mounts the host directory dir in the guest, so that the database files in it can be opened with
+// Runtime.OpenDatabase, attached with DB.Attach or written by DB.Backup. WithFile takes precedence, as it mounts the
+// directory of the file.
+func WithDir(dir string) Option {
+	return func(c *config) {
+		c.dir = dir
+	}
+}
+
+// WithSandbox restricts SQLite to what running untrusted SQL needs, for maximum isolation from the host:
+//
+//   - Only in-memory databases can be used, as no host directory is mounted. Open, NewPool and NewRuntime fail if
+//     WithFile, WithDir or WithSystemClock is also given, and so does OpenFromBytes, which needs a file.
+//   - The guest sees wazero's fake clocks instead of the host's, and no environment variables, arguments or standard
+//     input and output.
+//   - "PRAGMA trusted_schema = OFF" is set on every database, so that SQL functions with side effects can't be run
+//     from views or triggers.
+func WithSandbox() Option {
+	return func(c *config) {
+		c.sandbox = true
+	}
+}
+
+// validate returns an error if the options contradict each other.
+func (c *config) validate() error {
+	if !c.sandbox {
+		return nil
+	}
+	switch {
+	case c.file != "":
+		return errors.New("WithSandbox allows only in-memory databases, but WithFile is given")
+	case c.dir != "":
+		return errors.New("WithSandbox doesn't allow mounting a directory, but WithDir is given")
+	case c.sysClock:
+		return errors.New("WithSandbox doesn't allow the host clock, but WithSystemClock is given")
+	}
+	return nil
+}
+
+// moduleConfig returns the wazero.ModuleConfig to instantiate the SQLite module with.
+func (c *config) moduleConfig() wazero.ModuleConfig {
+	mc := wazero.NewModuleConfig()
+	if c.abi == ABIWasiSDK {
+		mc = mc.WithStartFunctions("_initialize")
+	}
+	return mc
+}
+
+// mountedDir returns the host directory mounted as the root in the guest, or empty if none is.
+func (c *config) mountedDir() string {
+	if c.file != "" {
+		return filepath.Dir(c.file)
+	}
+	return c.dir
+}
+
+// dbName returns the name to open the database with in the guest.
+func (c *config) dbName() string {
+	if c.file == "" {
+		return ":memory:"
+	}
+	// The directory of the file is mounted as the root.
+	return "/" + filepath.Base(c.file)
+}
+
+// InvalidUTF8Mode controls what happens when text read from a column isn't valid UTF-8.
+type InvalidUTF8Mode int
+
+const (
+	// InvalidUTF8PassThrough returns the text as-is, even if it contains invalid UTF-8 sequences. This is the default.
+	InvalidUTF8PassThrough InvalidUTF8Mode = iota
+	// InvalidUTF8Replace replaces each run of invalid bytes with the Unicode replacement character U+FFFD.
+	InvalidUTF8Replace
+	// InvalidUTF8Error fails the read with ErrInvalidUTF8.
+	InvalidUTF8Error
+)
+
+// ErrInvalidUTF8 is returned when reading text which isn't valid UTF-8 with InvalidUTF8Error.
+var ErrInvalidUTF8 = errors.New("text is not valid UTF-8")
+
+// WithInvalidUTF8 sets how text columns containing invalid UTF-8 are handled. Defaults to InvalidUTF8PassThrough.
+func WithInvalidUTF8(mode InvalidUTF8Mode) Option {
+	return func(c *config) {
+		c.invalidUTF8 = mode
+	}
+}
+
+// apply returns s handled according to the mode.
+func (m InvalidUTF8Mode) apply(s string) (string, error) {
+	if m == InvalidUTF8PassThrough || utf8.ValidString(s) {
+		return s, nil
+	}
+
+	switch m {
+	case InvalidUTF8Replace:
+		return strings.ToValidUTF8(s, string(utf8.RuneError)), nil
+	case InvalidUTF8Error:
+		return "", ErrInvalidUTF8
+	default:
+		return "", fmt.Errorf("invalid InvalidUTF8Mode %d", m)
+	}
+}
+
+// WithCloseStats makes DB.Close log a summary of the use of the database to the logger of WithLogger, e.g. for batch
+// jobs and CLIs: the number of statements prepared and executed, the errors by result code, the size of the guest
+// memory, which only grows and is therefore its peak, and the statements which took the longest in total.
+//
+// Note: page cache hit rates aren't reported, as the Wasm build doesn't export sqlite3_db_status.
+func WithCloseStats() Option {
+	return func(c *config) {
+		c.closeStats = true
+	}
+}
+
+// WithStrictScan makes Rows.Scan fail with ErrTypeMismatch when the storage class of a column doesn't match the type
+// of the destination, instead of converting the value with SQLite's rules, to catch schema and type bugs early:
+//
+//   - *int, *int64 and *bool accept only INTEGER.
+//   - *float64 and *float32 accept only REAL.
+//   - *string accepts only TEXT, and *[]byte only BLOB.
+//   - *time.Time accepts TEXT, INTEGER and REAL, its three representations in SQLite.
+//
+// NULL is a mismatch for all of them, and needs *Null[T], which checks non-NULL values as T. *Value, *any and
+// sql.Scanner accept any storage class.
+func WithStrictScan() Option {
+	return func(c *config) {
+		c.strictScan = true
+	}
+}
+
+// WithTrapDump makes TrapError carry a copy of the guest memory at the time of the trap, e.g. to attach to a bug
+// report. The copy is as large as the memory of the instance, so this is meant for debugging.
+func WithTrapDump() Option {
+	return func(c *config) {
+		c.trapDump = true
+	}
+}
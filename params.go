@@ -0,0 +1,196 @@
+package wazerosqlite
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NamedArg is an argument bound to a named parameter, created with Named.
+type NamedArg struct {
+	// Name is the name of the parameter, with or without its prefix ':', '@' or '$'.
+	Name string
+	// Value is the value bound to the parameter. See Stmt.Bind for the supported types.
+	Value any
+}
+
+// Named returns an argument for the named parameter in DB.Query and Stmt.Exec, e.g.
+//
+//	db.Query(ctx, "SELECT * FROM users WHERE id = :id", wazerosqlite.Named("id", 3))
+//
+// The name may omit the prefix, in which case it matches ":name", "@name" or "$name" in the query.
+func Named(name string, v any) NamedArg {
+	return NamedArg{Name: name, Value: v}
+}
+
+// BindNamed binds v to the named parameter. See Named for the name, and Bind for the supported types.
+func (s *Stmt) BindNamed(ctx context.Context, name string, v any) error {
+	index, err := s.parameterIndex(name)
+	if err != nil {
+		return err
+	}
+	return s.Bind(ctx, index, v)
+}
+
+// bindArgs binds args to the parameters of the statement. NamedArg is bound by name. The others are bound by position
+// starting from 1 if there is no NamedArg, and otherwise in order to the anonymous parameters, "?" and "?NNN", so
+// that they don't overwrite the named ones.
+func (s *Stmt) bindArgs(ctx context.Context, args []any) error {
+	hasNamed := false
+	for _, arg := range args {
+		if _, ok := arg.(NamedArg); ok {
+			hasNamed = true
+			break
+		}
+	}
+
+	position := 0
+	for _, arg := range args {
+		if named, ok := arg.(NamedArg); ok {
+			if err := s.BindNamed(ctx, named.Name, named.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		index := position + 1
+		if hasNamed {
+			s.parseParameters()
+			if position >= len(s.anonymous) {
+				return fmt.Errorf("%d positional arguments for %d anonymous parameters", position+1, len(s.anonymous))
+			}
+			index = s.anonymous[position]
+		}
+		position++
+		if err := s.Bind(ctx, index, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseParameters fills params and anonymous from the query, the first time it is called.
+func (s *Stmt) parseParameters() {
+	if s.params == nil {
+		s.params, s.anonymous = parameterIndexes(s.query)
+	}
+}
+
+// parameterIndex returns the index of the named parameter.
+func (s *Stmt) parameterIndex(name string) (int, error) {
+	s.parseParameters()
+
+	if index, ok := s.params[name]; ok {
+		return index, nil
+	}
+	for _, prefix := range []string{":", "@", "$"} {
+		if index, ok := s.params[prefix+name]; ok {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("no parameter named %q", name)
+}
+
+// parameterIndexes returns the indexes of the named parameters in the query, by their name including the prefix, and
+// the indexes of the anonymous parameters, "?" and "?NNN", in ascending order without duplicates.
+//
+// Note: the Wasm build doesn't export sqlite3_bind_parameter_index, so the query is scanned for parameters here,
+// skipping literals, quoted identifiers and comments, and numbered the way SQLite does: "?NNN" has the index NNN,
+// and each "?" or new name gets the largest index so far plus one.
+func parameterIndexes(query string) (map[string]int, []int) {
+	indexes := map[string]int{}
+	var anonymous []int
+	n := 0
+	for i := 0; i < len(query); {
+		if j := skipIgnored(query, i); j > i {
+			i = j
+			continue
+		}
+
+		switch c := query[i]; {
+		case c == '?':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				n++
+				anonymous = append(anonymous, n)
+			} else if index, err := strconv.Atoi(query[i+1 : j]); err == nil {
+				indexes[query[i:j]] = index
+				anonymous = append(anonymous, index)
+				if index > n {
+					n = index
+				}
+			}
+			i = j
+		case c == ':' || c == '@' || c == '$':
+			j := i + 1
+			for j < len(query) && isIdentifierChar(query[j]) {
+				j++
+			}
+			if j > i+1 {
+				if _, ok := indexes[query[i:j]]; !ok {
+					n++
+					indexes[query[i:j]] = n
+				}
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+
+	sort.Ints(anonymous)
+	unique := anonymous[:0]
+	for i, index := range anonymous {
+		if i == 0 || index != anonymous[i-1] {
+			unique = append(unique, index)
+		}
+	}
+	return indexes, unique
+}
+
+// skipIgnored returns the index after the literal, quoted identifier or comment starting at i, or i if there is none.
+func skipIgnored(query string, i int) int {
+	switch c := query[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		return skipQuoted(query, i, c)
+	case c == '[':
+		return skipQuoted(query, i, ']')
+	case strings.HasPrefix(query[i:], "--"):
+		if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+			return i + end + 1
+		}
+		return len(query)
+	case strings.HasPrefix(query[i:], "/*"):
+		if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+			return i + end + 4
+		}
+		return len(query)
+	}
+	return i
+}
+
+// skipQuoted returns the index after the quoted string starting at i and ending with end, where doubled end
+// characters are escaped.
+func skipQuoted(query string, i int, end byte) int {
+	for i++; i < len(query); i++ {
+		if query[i] != end {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == end && end != ']' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// isIdentifierChar returns true if c can be part of a parameter name.
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,141 @@
+package wazerosqlite
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParameterIndexes(t *testing.T) {
+	tests := []struct {
+		query     string
+		named     map[string]int
+		anonymous []int
+	}{
+		{query: "SELECT ?, ?", named: map[string]int{}, anonymous: []int{1, 2}},
+		{query: "SELECT :a, ?, @b, $c", named: map[string]int{":a": 1, "@b": 3, "$c": 4}, anonymous: []int{2}},
+		{query: "SELECT :a, :a, ?", named: map[string]int{":a": 1}, anonymous: []int{2}},
+		{query: "SELECT ?3, ?, ?1", named: map[string]int{"?3": 3, "?1": 1}, anonymous: []int{1, 3, 4}},
+		{query: "SELECT ':a', \"?\", [@b] -- $c\n/* ? */, :d", named: map[string]int{":d": 1}},
+	}
+	for _, tt := range tests {
+		named, anonymous := parameterIndexes(tt.query)
+		if !reflect.DeepEqual(named, tt.named) || !reflect.DeepEqual(anonymous, tt.anonymous) {
+			t.Errorf("parameterIndexes(%q) = %v, %v, want %v, %v", tt.query, named, anonymous, tt.named, tt.anonymous)
+		}
+	}
+}
+
+// queryStrings runs the query and returns the text of the columns of its first row, with NULL as "NULL".
+func queryStrings(t *testing.T, db *DB, query string, args ...any) ([]string, error) {
+	t.Helper()
+	ctx := context.Background()
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("no row: %v", rows.Err())
+	}
+	values, err := rows.ScanSlice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range values {
+		if v == nil {
+			got = append(got, "NULL")
+		} else {
+			got = append(got, v.(string))
+		}
+	}
+	return got, nil
+}
+
+func TestBindNamedArgs(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		want  []string
+	}{
+		{
+			name:  "colon",
+			query: "SELECT :a, :b",
+			args:  []any{Named(":b", "B"), Named("a", "A")},
+			want:  []string{"A", "B"},
+		},
+		{
+			name:  "at",
+			query: "SELECT @a, @b",
+			args:  []any{Named("@b", "B"), Named("a", "A")},
+			want:  []string{"A", "B"},
+		},
+		{
+			name:  "dollar",
+			query: "SELECT $a, $b",
+			args:  []any{Named("$b", "B"), Named("a", "A")},
+			want:  []string{"A", "B"},
+		},
+		{
+			name:  "mixed",
+			query: "SELECT :a, ?",
+			args:  []any{Named(":a", "named"), "pos"},
+			want:  []string{"named", "pos"},
+		},
+		{
+			name:  "positional before named",
+			query: "SELECT ?, :a, ?",
+			args:  []any{"first", Named("a", "named"), "second"},
+			want:  []string{"first", "named", "second"},
+		},
+		{
+			name:  "numbered",
+			query: "SELECT ?2, :a, ?1",
+			args:  []any{"one", "two", Named("a", "named")},
+			want:  []string{"two", "named", "one"},
+		},
+		{
+			name:  "positional only",
+			query: "SELECT ?, ?",
+			args:  []any{"one", "two"},
+			want:  []string{"one", "two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queryStrings(t, db, tt.query, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindNamedArgsErrors(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	if _, err = db.Query(ctx, "SELECT :a", Named("b", 1)); err == nil || !strings.Contains(err.Error(), `"b"`) {
+		t.Errorf("binding a missing name: got error %v", err)
+	}
+	if _, err = db.Query(ctx, "SELECT :a, ?", Named("a", 1), 2, 3); err == nil {
+		t.Error("binding more positional arguments than anonymous parameters succeeded")
+	}
+}
@@ -0,0 +1,447 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrPoolClosed is returned by Pool.Acquire after Pool.Close or Pool.Shutdown.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// ErrPinnedReader is returned by Pool.AcquireWriter when the Conn pinned to the context was acquired for reading, as
+// it can't be upgraded without waiting for itself.
+var ErrPinnedReader = errors.New("conn pinned to the context is a reader")
+
+// Pool is a fixed-size pool of DBs for concurrent use. Each DB is a separate SQLite module instance, instantiated from
+// the same compiled module, and all of them open the same database file.
+//
+// Note: SQLite can't lock files through WASI, so the instances can't coordinate with each other like processes
+// sharing a database file normally do. Instead, the pool lets any number of readers run in parallel via Acquire, but
+// a writer acquired via AcquireWriter excludes all the others.
+type Pool struct {
+	// r is the runtime shared by all the instances.
+	r wazero.Runtime
+	// compiledSqlite is the module the instances are instantiated from.
+	compiledSqlite wazero.CompiledModule
+	// c is the configuration the pool was created with.
+	c *config
+	// w is the WASI host of the runtime.
+	w *wasi
+	// idle holds the DBs not acquired.
+	idle chan *DB
+	// rw serializes writers against readers.
+	rw sync.RWMutex
+	// closeOnce guards releasing the DBs and the runtime.
+	closeOnce sync.Once
+	// closed is closed on Close or Shutdown to unblock Acquire.
+	closed chan struct{}
+	// mu guards the fields below.
+	mu sync.Mutex
+	// stopped is true once closed is closed.
+	stopped bool
+	// out is the number of Conns acquired and not released yet, not counting those for a pinned Conn.
+	out int
+	// drained is closed once the pool is stopped and all the Conns are released.
+	drained chan struct{}
+	// dbs are all the DBs in the pool.
+	dbs []*DB
+	// instances is the number of module instances created, which names the next one.
+	instances int
+	// replacing counts the replacements of trapped instances in progress, which Close waits for.
+	replacing sync.WaitGroup
+	// busyTimeout bounds how long Conn retries statements failing with CodeBusy or CodeLocked.
+	busyTimeout time.Duration
+}
+
+// Conn is a DB acquired from a Pool. It must be released with Release after use, and must not be used afterwards.
+type Conn struct {
+	*DB
+	// p is the pool this Conn was acquired from.
+	p *Pool
+	// write is true if this Conn holds the write lock of the pool.
+	write bool
+	// pinned is true if this Conn was returned for the Conn pinned to the context, which holds the DB instead.
+	pinned bool
+}
+
+// connKey is the context key of the Conn pinned by WithConn.
+type connKey struct{}
+
+// WithConn returns a copy of ctx with c pinned to it. Pool.Acquire and Pool.AcquireWriter of the pool of c, called with
+// the returned context or a context derived from it, then return a Conn on the same DB without waiting, so that a
+// request-scoped sequence of statements reliably runs on the same instance across library layers, e.g. to share
+// temporary tables, LastInsertRowID or a transaction.
+//
+// Releasing the returned Conns does nothing: the DB goes back to the pool when c is released, after which the context
+// must no longer be used to acquire. As any Conn, the Conns on the DB must not be used concurrently.
+func WithConn(ctx context.Context, c *Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// ConnFromContext returns the Conn pinned to ctx by WithConn, if any.
+func ConnFromContext(ctx context.Context) (*Conn, bool) {
+	c, ok := ctx.Value(connKey{}).(*Conn)
+	return c, ok
+}
+
+// NewPool creates a Pool of `size` DBs on the file specified by WithFile, which is required as in-memory databases
+// can't be shared between module instances.
+func NewPool(ctx context.Context, size int, opts ...Option) (*Pool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid pool size %d", size)
+	}
+
+	c := newConfig(opts)
+	if c.file == "" {
+		return nil, errors.New("pool requires a database file: use WithFile")
+	}
+
+	r, compiledSqlite, w, err := newRuntime(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pool{
+		r:              r,
+		compiledSqlite: compiledSqlite,
+		c:              c,
+		w:              w,
+		idle:           make(chan *DB, size),
+		closed:         make(chan struct{}),
+		drained:        make(chan struct{}),
+		busyTimeout:    c.busyTimeout,
+	}
+	for i := 0; i < size; i++ {
+		db, err := p.open(ctx)
+		if err != nil {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+		p.dbs = append(p.dbs, db)
+		p.idle <- db
+	}
+	return p, nil
+}
+
+// open instantiates a new module instance and opens the database in it.
+func (p *Pool) open(ctx context.Context) (*DB, error) {
+	p.mu.Lock()
+	// Each instance needs a unique name in the runtime.
+	name := fmt.Sprintf("sqlite-%d", p.instances)
+	p.instances++
+	p.mu.Unlock()
+	return open(ctx, p.r, p.compiledSqlite, p.c, p.c.moduleConfig().WithName(name))
+}
+
+// Acquire waits for an idle DB to read from. Reads on different Conns run in parallel. If a Conn of the pool is pinned
+// to ctx by WithConn, a Conn on its DB is returned instead.
+func (p *Pool) Acquire(ctx context.Context) (*Conn, error) {
+	return p.acquire(ctx, false)
+}
+
+// AcquireWriter waits for an idle DB, and then for all the other Conns to be released, so that it can write
+// exclusively. New readers wait until the writer is released. Both waits end with the error of ctx once it is done.
+// If a writer of the pool is pinned to ctx by WithConn, a Conn on its DB is returned instead, and ErrPinnedReader if
+// a reader is.
+func (p *Pool) AcquireWriter(ctx context.Context) (*Conn, error) {
+	return p.acquire(ctx, true)
+}
+
+func (p *Pool) acquire(ctx context.Context, write bool) (*Conn, error) {
+	if c, ok := ConnFromContext(ctx); ok && c.p == p {
+		if write && !c.write {
+			return nil, ErrPinnedReader
+		}
+		return &Conn{DB: c.DB, p: p, write: c.write, pinned: true}, nil
+	}
+
+	select {
+	case <-p.closed:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case db := <-p.idle:
+		if err := p.lock(ctx, write); err != nil {
+			p.idle <- db
+			return nil, err
+		}
+		c := &Conn{DB: db, p: p, write: write}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// The pool may have been stopped while waiting for the lock.
+		if p.stopped {
+			c.unlock()
+			return nil, ErrPoolClosed
+		}
+		p.out++
+		return c, nil
+	}
+}
+
+// lock takes rw for writing or reading, unless ctx is done or the pool is closed first. As sync.RWMutex can't be
+// cancelled, the lock is then released as soon as it is taken.
+func (p *Pool) lock(ctx context.Context, write bool) error {
+	locked := make(chan struct{})
+	go func() {
+		if write {
+			p.rw.Lock()
+		} else {
+			p.rw.RLock()
+		}
+		close(locked)
+	}()
+
+	var err error
+	select {
+	case <-locked:
+		return nil
+	case <-p.closed:
+		err = ErrPoolClosed
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	go func() {
+		<-locked
+		if write {
+			p.rw.Unlock()
+		} else {
+			p.rw.RUnlock()
+		}
+	}()
+	return err
+}
+
+// Release returns the Conn to the pool, unless it was returned for the Conn pinned to the context. If the instance of
+// the Conn trapped, it is closed instead, and replaced by a new one in the background.
+func (c *Conn) Release() {
+	if c.pinned {
+		return
+	}
+	p := c.p
+	if c.DB.m.trap != nil {
+		c.unlockRW()
+		p.replace(c.DB)
+	} else {
+		c.unlock()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out--
+	if p.stopped && p.out == 0 {
+		close(p.drained)
+	}
+}
+
+// unlock releases the lock of the Conn and returns its DB to the idle ones.
+func (c *Conn) unlock() {
+	c.unlockRW()
+	c.p.idle <- c.DB
+}
+
+// unlockRW releases the lock of the Conn.
+func (c *Conn) unlockRW() {
+	if c.write {
+		c.p.rw.Unlock()
+	} else {
+		c.p.rw.RUnlock()
+	}
+}
+
+// replace closes the module instance of the DB, which trapped, and opens another one in the background so that the
+// pool keeps its size. The pool shrinks if that fails, which is logged.
+func (p *Pool) replace(db *DB) {
+	p.mu.Lock()
+	for i, d := range p.dbs {
+		if d == db {
+			p.dbs = append(p.dbs[:i], p.dbs[i+1:]...)
+			break
+		}
+	}
+	// Close waits for the replacements, so none may start once the pool is stopped.
+	stopped := p.stopped
+	if !stopped {
+		p.replacing.Add(1)
+	}
+	p.mu.Unlock()
+
+	// The state of the instance is unknown, so the database isn't closed via sqlite3_close, which could trap again.
+	ctx := context.Background()
+	_ = db.m.mod.Close(ctx)
+	p.w.release(db.m.mod)
+	if stopped {
+		return
+	}
+
+	go func() {
+		defer p.replacing.Done()
+		replacement, err := p.open(ctx)
+		if err != nil {
+			p.c.logger.Printf("wazerosqlite: failed to replace a trapped module instance: %v", err)
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.dbs = append(p.dbs, replacement)
+		p.idle <- replacement
+	}()
+}
+
+// Raw calls fn with the SQLite module instance of the Conn and the handle of its database, i.e. the sqlite3 pointer
+// in the guest memory, to call SQLite exports this package doesn't wrap. As each Conn has its own module instance and
+// is held by a single caller until Release, nothing else runs in the instance during fn.
+//
+// fn must not keep mod or dbHandle after it returns, nor close the database. Memory allocated in the guest by fn is
+// its to release.
+func (c *Conn) Raw(fn func(mod api.Module, dbHandle uint32) error) error {
+	return fn(c.DB.m.mod, c.DB.handle)
+}
+
+// Exec is like DB.Exec, but retries with backoff while the database is busy or locked, for up to the duration set
+// with WithBusyTimeout. Only a query of a single statement is retried, since retrying a script would run again the
+// statements which succeeded before the busy one.
+//
+// A panic during the call is recovered, and like a trap of the guest, returned as a TrapError. See Release.
+func (c *Conn) Exec(ctx context.Context, query string) (err error) {
+	if err = c.trapped(); err != nil {
+		return err
+	}
+	defer c.recover(ctx, &err)
+
+	if len(SplitStatements(query)) > 1 {
+		return c.DB.Exec(ctx, query)
+	}
+	return c.retry(ctx, func() error {
+		return c.DB.Exec(ctx, query)
+	})
+}
+
+// Query is like DB.Query, but retries with backoff while preparing the query fails because the database is busy or
+// locked, for up to the duration set with WithBusyTimeout. Errors while stepping through the rows are not retried.
+//
+// A panic during the call is recovered, and like a trap of the guest, returned as a TrapError. See Release.
+func (c *Conn) Query(ctx context.Context, query string, args ...any) (rows *Rows, err error) {
+	if err = c.trapped(); err != nil {
+		return nil, err
+	}
+	defer c.recover(ctx, &err)
+
+	err = c.retry(ctx, func() (err error) {
+		rows, err = c.DB.Query(ctx, query, args...)
+		return
+	})
+	return
+}
+
+// trapped returns the TrapError of the instance of the Conn, if it trapped.
+func (c *Conn) trapped() error {
+	if trap := c.DB.m.trap; trap != nil {
+		return trap
+	}
+	return nil
+}
+
+// recover records a panic of the call it is deferred by as the trap of the instance, so that it doesn't unwind
+// through the caller, and sets *err to the TrapError if the instance trapped during the call.
+func (c *Conn) recover(ctx context.Context, err *error) {
+	if r := recover(); r != nil {
+		c.DB.m.setTrap(ctx, fmt.Errorf("panic: %v\n%s", r, debug.Stack()))
+	}
+	if trapErr := c.trapped(); trapErr != nil {
+		*err = trapErr
+	}
+}
+
+// retry calls fn until it succeeds, fails with an error other than IsBusy, or the busy timeout of the pool elapses.
+//
+// Note: with the bundled SQLite binary, the directory mounted by WithFile has no file locking, so SQLite never reports
+// the database as busy or locked to the instances of a pool, and this never retries: the pool's rw lock is what keeps
+// writers exclusive. The retry is for builds and file systems where locks can fail.
+func (c *Conn) retry(ctx context.Context, fn func() error) error {
+	deadline := time.Now().Add(c.p.busyTimeout)
+	backoff := time.Millisecond
+	for {
+		err := fn()
+		if err == nil || !IsBusy(err) || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if backoff < 100*time.Millisecond {
+			backoff *= 2
+		}
+	}
+}
+
+// Size returns the number of DBs in the pool, which is less than the size it was created with while the instance of
+// a DB which trapped is being replaced.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.dbs)
+}
+
+// Close closes all the DBs and the runtime. Conns in use must not be used afterwards. See Shutdown to wait for them.
+func (p *Pool) Close(ctx context.Context) (err error) {
+	p.stop()
+	p.closeOnce.Do(func() {
+		p.replacing.Wait()
+		p.mu.Lock()
+		dbs := p.dbs
+		p.mu.Unlock()
+		for _, db := range dbs {
+			if closeErr := db.closeDB(ctx); err == nil {
+				err = closeErr
+			}
+		}
+		if closeErr := p.r.Close(ctx); err == nil {
+			err = closeErr
+		}
+	})
+	return
+}
+
+// Shutdown stops handing out Conns, so that Acquire returns ErrPoolClosed, waits for the Conns in use to be released,
+// and closes the pool like Close, e.g. on the termination of a service.
+//
+// If ctx is done first, the pool is closed anyway and the error of ctx is returned. The guest can't be interrupted
+// (the bundled SQLite doesn't export sqlite3_interrupt), so the DBs of the Conns still in use are closed under them,
+// and they must not be used anymore. No checkpoint is needed, as the bundled SQLite uses a rollback journal which is
+// already removed once each transaction commits.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.stop()
+	select {
+	case <-p.drained:
+		return p.Close(ctx)
+	case <-ctx.Done():
+		_ = p.Close(ctx)
+		return ctx.Err()
+	}
+}
+
+// stop makes Acquire return ErrPoolClosed.
+func (p *Pool) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	close(p.closed)
+	if p.out == 0 {
+		close(p.drained)
+	}
+}
@@ -0,0 +1,542 @@
+package wazerosqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+func newTestPool(t *testing.T, size int) *Pool {
+	t.Helper()
+	ctx := context.Background()
+	p, err := NewPool(ctx, size, WithFile(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close(ctx) })
+
+	c, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Release()
+	if err = c.Exec(ctx, "CREATE TABLE t (a); INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// acquireAsync acquires a Conn in a goroutine, and returns the channel the result is sent to.
+func acquireAsync(ctx context.Context, p *Pool, write bool) chan error {
+	done := make(chan error, 1)
+	go func() {
+		c, err := p.acquire(ctx, write)
+		if err == nil {
+			c.Release()
+		}
+		done <- err
+	}()
+	return done
+}
+
+// assertBlocked fails if done receives within a short time.
+func assertBlocked(t *testing.T, done chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		t.Fatalf("acquired while it should wait: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPoolConcurrentReaders(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 3)
+
+	// All the DBs can be acquired for reading at the same time, and read in parallel.
+	var conns []*Conn
+	for i := 0; i < p.Size(); i++ {
+		c, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		wg.Add(1)
+		go func(c *Conn) {
+			defer wg.Done()
+			defer c.Release()
+			rows, err := c.Query(ctx, "SELECT count(*) FROM t")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer rows.Close()
+			var n int
+			if !rows.Next() || rows.Scan(&n) != nil || n != 1 {
+				t.Errorf("got %d, %v", n, rows.Err())
+			}
+		}(c)
+	}
+	wg.Wait()
+}
+
+func TestPoolWriterExclusive(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 3)
+
+	// The writer waits for the reader.
+	r, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := make(chan *Conn, 1)
+	go func() {
+		w, err := p.AcquireWriter(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		writer <- w
+	}()
+	select {
+	case <-writer:
+		t.Fatal("writer acquired while a reader is out")
+	case <-time.After(50 * time.Millisecond):
+	}
+	r.Release()
+	w := <-writer
+	if w == nil {
+		return
+	}
+
+	// Readers wait for the writer.
+	done := acquireAsync(ctx, p, false)
+	assertBlocked(t, done)
+	if err = w.Exec(ctx, "INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	w.Release()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// All the instances see the write.
+	var conns []*Conn
+	for i := 0; i < p.Size(); i++ {
+		c, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+		got, err := queryStrings(t, c.DB, "SELECT group_concat(a) FROM t")
+		if err != nil || got[0] != "1,2" {
+			t.Errorf("DB %d: got %v, %v", i, got, err)
+		}
+	}
+	for _, c := range conns {
+		c.Release()
+	}
+}
+
+func TestPoolAcquireCancel(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 2)
+
+	// Waiting for an idle DB.
+	conns := []*Conn{}
+	for i := 0; i < p.Size(); i++ {
+		c, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	done := acquireAsync(cancelCtx, p, false)
+	assertBlocked(t, done)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	conns[1].Release()
+
+	// Waiting for the readers to be released.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.AcquireWriter(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// The DB and the lock taken after the cancellation are given back.
+	conns[0].Release()
+	w, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Release()
+	for i := 0; i < p.Size(); i++ {
+		c, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Release()
+	}
+}
+
+func TestPoolCloseWithConnsOut(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 1)
+
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := acquireAsync(ctx, p, false)
+	assertBlocked(t, done)
+
+	if err = p.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("waiting Acquire: got %v, want ErrPoolClosed", err)
+	}
+	// Releasing after Close doesn't block.
+	c.Release()
+	if _, err = p.Acquire(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Acquire after Close: got %v, want ErrPoolClosed", err)
+	}
+	if err = p.Close(ctx); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestConnRaw(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 1)
+
+	c, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Release()
+	if err = c.Exec(ctx, "INSERT INTO t VALUES (2), (3)"); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Raw(func(mod api.Module, dbHandle uint32) error {
+		res, err := mod.ExportedFunction("sqlite3_changes").Call(ctx, uint64(dbHandle))
+		if err != nil {
+			return err
+		}
+		if res[0] != 2 {
+			t.Errorf("sqlite3_changes = %d, want 2", res[0])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := errors.New("fn failed")
+	if err = c.Raw(func(api.Module, uint32) error { return want }); err != want {
+		t.Errorf("got %v, want the error of fn", err)
+	}
+}
+
+func TestConnRetry(t *testing.T) {
+	busy := newError(int(CodeBusy), "database is locked", "")
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "busy then success", errs: []error{busy, busy, nil}, wantCalls: 3},
+		{name: "other error", errs: []error{errors.New("x"), nil}, wantErr: errors.New("x"), wantCalls: 1},
+		{name: "busy until the timeout", errs: nil, wantErr: busy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conn{p: &Pool{busyTimeout: 50 * time.Millisecond}}
+			calls := 0
+			start := time.Now()
+			err := c.retry(context.Background(), func() error {
+				calls++
+				if calls > len(tt.errs) {
+					return busy
+				}
+				return tt.errs[calls-1]
+			})
+			if (err == nil) != (tt.wantErr == nil) || err != nil && err.Error() != tt.wantErr.Error() {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantCalls > 0 && calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", calls, tt.wantCalls)
+			}
+			if tt.errs == nil && (calls < 2 || time.Since(start) > time.Second) {
+				t.Errorf("retried %d times in %s", calls, time.Since(start))
+			}
+		})
+	}
+
+	// The wait between retries ends with ctx.
+	c := &Conn{p: &Pool{busyTimeout: time.Minute}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.retry(ctx, func() error { return busy }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestWithConn(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 2)
+
+	w, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned := WithConn(ctx, w)
+	if c, ok := ConnFromContext(pinned); !ok || c != w {
+		t.Errorf("got %v, %t from the context", c, ok)
+	}
+
+	// Without the pinned context, acquiring waits for the writer.
+	done := acquireAsync(ctx, p, false)
+	assertBlocked(t, done)
+
+	// With it, every layer gets the DB of the writer, and shares its temporary tables and transaction.
+	layer, err := p.AcquireWriter(pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.DB != w.DB {
+		t.Error("got another DB than the pinned one")
+	}
+	if err = layer.Exec(ctx, "CREATE TEMP TABLE tmp (a); BEGIN; INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	layer.Release()
+	derived, cancel := context.WithCancel(pinned)
+	defer cancel()
+	reader, err := p.Acquire(derived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := reader.LastInsertRowID(ctx); err != nil || id != 2 {
+		t.Errorf("got last insert rowid %d, %v, want 2", id, err)
+	}
+	got, err := queryStrings(t, reader.DB, "SELECT group_concat(a) || (SELECT count(*) FROM tmp) FROM t")
+	if err != nil || got[0] != "1,20" {
+		t.Errorf("got %v, %v", got, err)
+	}
+	reader.Release()
+	if err = w.Exec(ctx, "COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The DB goes back to the pool only with the pinned Conn.
+	assertBlocked(t, done)
+	w.Release()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader can't be upgraded.
+	r, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Release()
+	pinned = WithConn(ctx, r)
+	if _, err = p.AcquireWriter(pinned); !errors.Is(err, ErrPinnedReader) {
+		t.Errorf("got %v, want ErrPinnedReader", err)
+	}
+	if c, err := p.Acquire(pinned); err != nil || c.DB != r.DB {
+		t.Errorf("got %v, %v for a reader", c, err)
+	}
+
+	// A Conn of another pool is ignored.
+	other, err := newTestPool(t, 1).Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Release()
+	c, err := p.Acquire(WithConn(ctx, other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Release()
+	if c.DB == other.DB || c.DB == r.DB {
+		t.Error("got the DB of the Conn pinned for another pool")
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 2)
+
+	c, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A reader waiting for the writer gets no Conn once the pool shuts down.
+	waiting := acquireAsync(ctx, p, false)
+	assertBlocked(t, waiting)
+
+	// Concurrent Shutdowns all wait for the Conn in use.
+	var shutdowns []chan error
+	for i := 0; i < 2; i++ {
+		done := make(chan error, 1)
+		go func() { done <- p.Shutdown(ctx) }()
+		shutdowns = append(shutdowns, done)
+	}
+	for _, done := range shutdowns {
+		assertBlocked(t, done)
+	}
+	if _, err = p.Acquire(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Acquire during Shutdown: got %v, want ErrPoolClosed", err)
+	}
+
+	// The Conn in use can still finish its work.
+	if err = c.Exec(ctx, "INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	c.Release()
+	if err = <-waiting; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("waiting Acquire: got %v, want ErrPoolClosed", err)
+	}
+	for _, done := range shutdowns {
+		if err = <-done; err != nil {
+			t.Errorf("Shutdown: %v", err)
+		}
+	}
+	if err = p.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown after Shutdown: %v", err)
+	}
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	ctx := context.Background()
+	p := newTestPool(t, 1)
+
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err = p.Shutdown(timeoutCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	// The pool is closed under the straggler.
+	if _, err = c.Query(ctx, "SELECT 1"); err == nil {
+		t.Error("queried a DB closed by Shutdown")
+	}
+	c.Release()
+}
+
+// panicValuer panics when bound.
+type panicValuer struct{}
+
+func (panicValuer) Value() (driver.Value, error) {
+	panic("boom")
+}
+
+func TestConnTrap(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "test.db")
+	p, err := NewPool(ctx, 1, WithFile(file), WithTrapDump())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close(ctx)
+	c, err := p.AcquireWriter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Exec(ctx, "CREATE TABLE t (a); INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	c.Release()
+
+	tests := []struct {
+		name string
+		// call makes the Conn trap or panic.
+		call func(c *Conn) error
+		want string
+	}{
+		{
+			name: "trap",
+			call: func(c *Conn) error {
+				// Stepping an invalid statement pointer reads out of the bounds of the memory.
+				_, err := c.DB.m.execStep(ctx, 0xfffffff0)
+				return err
+			},
+			want: "out of bounds memory access",
+		},
+		{
+			name: "panic",
+			call: func(c *Conn) error {
+				_, err := c.Query(ctx, "SELECT ?", panicValuer{})
+				return err
+			},
+			want: "panic: boom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mod := c.DB.m.mod
+			if err = tt.call(c); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want %q", err, tt.want)
+			}
+
+			// The Conn keeps failing with the trap.
+			var trapErr *TrapError
+			if err = c.Exec(ctx, "SELECT 1"); !errors.As(err, &trapErr) || !strings.Contains(trapErr.Err.Error(), tt.want) {
+				t.Errorf("got %v, want a TrapError", err)
+			} else if len(trapErr.Memory) == 0 {
+				t.Error("no memory dump")
+			}
+			if _, err = c.Query(ctx, "SELECT 1"); !errors.As(err, &trapErr) {
+				t.Errorf("got %v, want a TrapError", err)
+			}
+
+			// The instance is replaced on Release, and the state of its files released.
+			c.Release()
+			if c, err = p.Acquire(ctx); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Release()
+			if c.DB.m.mod == mod {
+				t.Error("got the trapped instance back")
+			}
+			if p.Size() != 1 {
+				t.Errorf("got size %d, want 1", p.Size())
+			}
+			p.w.mu.Lock()
+			_, ok := p.w.instances[mod]
+			p.w.mu.Unlock()
+			if ok {
+				t.Error("the WASI state of the trapped instance is kept")
+			}
+			got, err := queryStrings(t, c.DB, "SELECT group_concat(a) FROM t")
+			if err != nil || got[0] != "1" {
+				t.Errorf("got %v, %v from the replacement", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package wazerosqlite
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// QuoteIdentifier quotes name so that it can be used as an identifier, e.g. a table name, in SQL.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QuoteLiteral renders v as a SQL literal which evaluates to the same value, e.g. for generating SQL scripts.
+func QuoteLiteral(v Value) string {
+	switch v.Type() {
+	case TypeInteger:
+		return strconv.FormatInt(v.Int64(), 10)
+	case TypeFloat:
+		f := v.Float64()
+		switch {
+		case math.IsInf(f, 1):
+			return "1e999"
+		case math.IsInf(f, -1):
+			return "-1e999"
+		case math.IsNaN(f):
+			return "NULL"
+		}
+		// Unlike Value.Text, use the shortest representation which round-trips exactly.
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case TypeText:
+		return "'" + strings.ReplaceAll(v.Text(), "'", "''") + "'"
+	case TypeBlob:
+		return "X'" + strings.ToUpper(hex.EncodeToString(v.Blob())) + "'"
+	}
+	return "NULL"
+}
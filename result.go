@@ -0,0 +1,85 @@
+package wazerosqlite
+
+import "context"
+
+// Result summarizes the changes made by an INSERT, UPDATE or DELETE statement.
+type Result struct {
+	// RowsAffected is the number of rows inserted, updated or deleted.
+	RowsAffected int64
+	// LastInsertID is the rowid of the last row inserted into a rowid table on the database, which may have been
+	// inserted by an earlier statement.
+	LastInsertID int64
+}
+
+// ExecResult is like Exec, but executes a single statement with args bound to its parameters like Query, and returns
+// its Result. See Stmt.Bind for the supported argument types.
+func (db *DB) ExecResult(ctx context.Context, query string, args ...any) (Result, error) {
+	s, err := db.Prepare(ctx, query)
+	if err != nil {
+		return Result{}, err
+	}
+	defer s.Close(ctx)
+	return s.Exec(ctx, args...)
+}
+
+// Exec resets the statement, binds args to its parameters like DB.Query, and runs it to completion discarding any
+// rows. See Stmt.Bind for the supported argument types.
+func (s *Stmt) Exec(ctx context.Context, args ...any) (Result, error) {
+	if err := s.Reset(ctx); err != nil {
+		return Result{}, err
+	}
+	if err := s.bindArgs(ctx, args); err != nil {
+		return Result{}, err
+	}
+
+	for {
+		hasRow, err := s.Step(ctx)
+		if err != nil {
+			_ = s.Reset(ctx)
+			return Result{}, err
+		} else if !hasRow {
+			break
+		}
+	}
+	if err := s.Reset(ctx); err != nil {
+		return Result{}, err
+	}
+
+	changes, err := s.db.Changes(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	id, err := s.db.LastInsertRowID(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{RowsAffected: changes, LastInsertID: id}, nil
+}
+
+// Changes returns the number of rows inserted, updated or deleted by the most recent INSERT, UPDATE or DELETE
+// statement on the database, via sqlite3_changes.
+//
+// Note: the count is limited to 32 bits, as the Wasm build doesn't export sqlite3_changes64.
+func (db *DB) Changes(ctx context.Context) (int64, error) {
+	n, err := db.m.callInt(ctx, db.m.changes, "sqlite3_changes", uint64(db.handle))
+	return int64(n), err
+}
+
+// LastInsertRowID returns the rowid of the most recent successful INSERT into a rowid table on the database.
+//
+// Note: the Wasm build doesn't export sqlite3_last_insert_rowid, so this queries the last_insert_rowid() SQL
+// function instead.
+func (db *DB) LastInsertRowID(ctx context.Context) (int64, error) {
+	rows, err := db.Query(ctx, "SELECT last_insert_rowid()")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var id int64
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	err = rows.Scan(&id)
+	return id, err
+}
@@ -0,0 +1,356 @@
+package wazerosqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errRowsClosed is returned when Rows is used after Close.
+var errRowsClosed = errors.New("rows are closed")
+
+// ErrTypeMismatch is returned by Rows.Scan with WithStrictScan when the storage class of a column doesn't match the
+// destination.
+var ErrTypeMismatch = errors.New("storage class doesn't match the destination")
+
+// Rows is the result of DB.Query. Rows are read lazily, one step at a time, via Next. Only the columns passed to Scan
+// are copied out of the guest memory, so iterating over a large result set doesn't grow the Go heap.
+//
+//	rows, err := db.Query(ctx, "SELECT id, name FROM users WHERE id > ?", 1)
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		var id int64
+//		var name string
+//		if err := rows.Scan(&id, &name); err != nil {
+//			...
+//		}
+//	}
+//	if err := rows.Err(); err != nil {
+//		...
+//	}
+type Rows struct {
+	// ctx is used for the guest calls made while iterating.
+	ctx context.Context
+	// stmt is the statement which produces the rows. Rows owns it and finalizes it on Close.
+	stmt *Stmt
+	// err holds the error which stopped the iteration, if any.
+	err error
+	// closed is true after Close.
+	closed bool
+	// columns are the column names, read on the first ScanMap.
+	columns []string
+}
+
+// Query prepares the query, binds args to its parameters and returns the resulting rows. Arguments created with
+// Named are bound to the named parameters, and the others to the positional ones in order.
+//
+// See Stmt.Bind for the supported argument types. The returned Rows must be closed.
+func (db *DB) Query(ctx context.Context, query string, args ...any) (*Rows, error) {
+	stmt, err := db.Prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = stmt.bindArgs(ctx, args); err != nil {
+		_ = stmt.Close(ctx)
+		return nil, err
+	}
+	return &Rows{ctx: ctx, stmt: stmt}, nil
+}
+
+// Next advances to the next row, and returns false when there are no more rows or an error happened. Err should be
+// consulted to distinguish the two cases. If the context passed to DB.Query is done, Next stops and Err returns
+// ctx.Err().
+func (r *Rows) Next() bool {
+	if r.closed || r.err != nil {
+		return false
+	}
+
+	hasRow, err := r.stmt.Step(r.ctx)
+	if err != nil {
+		r.err = err
+		return false
+	}
+	return hasRow
+}
+
+// Err returns the error, if any, encountered during iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Columns returns the column names of the result set.
+func (r *Rows) Columns() ([]string, error) {
+	if r.closed {
+		return nil, errRowsClosed
+	}
+
+	n, err := r.stmt.ColumnCount(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, n)
+	for i := range columns {
+		if columns[i], err = r.stmt.ColumnName(r.ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	return columns, nil
+}
+
+// Scan copies the columns of the current row into dest, which must have the same number of elements as the columns
+// to read.
+//
+// Supported destinations are *int, *int64, *bool, *float64, *float32, *string, *[]byte, *time.Time, *Value and *any.
+// Values are converted with SQLite's rules, e.g. reading a TEXT column into *int64 parses the leading number in the
+// text. *time.Time accepts TEXT in the formats of ParseTime, INTEGER as Unix time and REAL as Julian day number.
+// *Value receives the value in its storage class, and *any receives the result of Value.Any. *int returns ErrOverflow
+// if the integer doesn't fit, which can only happen on 32-bit platforms.
+//
+// NULL is read as the zero value by the destinations above. To tell NULL apart, use *Null[T] with any of the types
+// above as T, or a sql.Scanner such as *sql.NullInt64, which receives the result of Value.Any.
+//
+// With WithStrictScan, these conversions are replaced with ErrTypeMismatch errors.
+func (r *Rows) Scan(dest ...any) error {
+	if r.closed {
+		return errRowsClosed
+	}
+
+	for i, d := range dest {
+		if err := r.scan(i, d); err != nil {
+			return fmt.Errorf("failed to scan column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rows) scan(i int, dest any) (err error) {
+	if r.stmt.db.strictScan {
+		if err = r.checkType(i, dest); err != nil {
+			return err
+		}
+	}
+
+	switch d := dest.(type) {
+	case *int:
+		var v int64
+		if v, err = r.stmt.ColumnInt64(r.ctx, i); err == nil {
+			*d, err = int64ToInt(v)
+		}
+	case *int64:
+		*d, err = r.stmt.ColumnInt64(r.ctx, i)
+	case *bool:
+		var v int64
+		v, err = r.stmt.ColumnInt64(r.ctx, i)
+		*d = v != 0
+	case *float64:
+		*d, err = r.stmt.ColumnFloat64(r.ctx, i)
+	case *float32:
+		var v float64
+		v, err = r.stmt.ColumnFloat64(r.ctx, i)
+		*d = float32(v)
+	case *string:
+		*d, err = r.stmt.ColumnText(r.ctx, i)
+	case *[]byte:
+		*d, err = r.stmt.ColumnBlob(r.ctx, i)
+	case *Value:
+		*d, err = r.stmt.ColumnValue(r.ctx, i)
+	case *any:
+		var v Value
+		v, err = r.stmt.ColumnValue(r.ctx, i)
+		*d = v.Any()
+	case *time.Time:
+		var v Value
+		if v, err = r.stmt.ColumnValue(r.ctx, i); err == nil {
+			*d, err = timeFromValue(v)
+		}
+	case columnScanner:
+		err = d.scanColumn(r, i)
+	case sql.Scanner:
+		var v Value
+		if v, err = r.stmt.ColumnValue(r.ctx, i); err == nil {
+			err = d.Scan(v.Any())
+		}
+	default:
+		err = fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return
+}
+
+// ScanSlice returns the columns of the current row, e.g. for ad-hoc queries whose columns aren't known in advance. The
+// values have the Go type of their storage class, like Value.Any: int64 for INTEGER, float64 for REAL, string for
+// TEXT, []byte for BLOB and nil for NULL.
+func (r *Rows) ScanSlice() ([]any, error) {
+	if r.closed {
+		return nil, errRowsClosed
+	}
+
+	n, err := r.stmt.ColumnCount(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, n)
+	for i := range values {
+		if err = r.scan(i, &values[i]); err != nil {
+			return nil, fmt.Errorf("failed to scan column %d: %w", i, err)
+		}
+	}
+	return values, nil
+}
+
+// ScanMap is like ScanSlice, but returns the values keyed by column name. Of columns with the same name, e.g. in a
+// join, the last one is kept.
+func (r *Rows) ScanMap() (map[string]any, error) {
+	if r.columns == nil {
+		columns, err := r.Columns()
+		if err != nil {
+			return nil, err
+		}
+		r.columns = columns
+	}
+
+	values, err := r.ScanSlice()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(values))
+	for i, v := range values {
+		m[r.columns[i]] = v
+	}
+	return m, nil
+}
+
+// checkType returns ErrTypeMismatch if the storage class of the i-th column isn't one dest accepts with
+// WithStrictScan.
+func (r *Rows) checkType(i int, dest any) error {
+	var accepted []ColumnType
+	switch dest.(type) {
+	case *int, *int64, *bool:
+		accepted = []ColumnType{TypeInteger}
+	case *float64, *float32:
+		accepted = []ColumnType{TypeFloat}
+	case *string:
+		accepted = []ColumnType{TypeText}
+	case *[]byte:
+		accepted = []ColumnType{TypeBlob}
+	case *time.Time:
+		accepted = []ColumnType{TypeText, TypeInteger, TypeFloat}
+	default:
+		return nil
+	}
+
+	t, err := r.stmt.ColumnType(r.ctx, i)
+	if err != nil {
+		return err
+	}
+	for _, a := range accepted {
+		if t == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s into %T", ErrTypeMismatch, t, dest)
+}
+
+// Close finalizes the underlying statement. It is safe to call Close multiple times.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.stmt.Close(r.ctx)
+}
+
+// Null is a destination of Rows.Scan which can be NULL. T can be any type whose pointer Rows.Scan supports.
+type Null[T any] struct {
+	// V is the value if Valid is true, or the zero value of T otherwise.
+	V T
+	// Valid is false if the value is NULL.
+	Valid bool
+}
+
+// columnScanner is implemented by destinations which scan the column by themselves, like Null.
+type columnScanner interface {
+	scanColumn(r *Rows, i int) error
+}
+
+func (n *Null[T]) scanColumn(r *Rows, i int) error {
+	t, err := r.stmt.ColumnType(r.ctx, i)
+	if err != nil {
+		return err
+	}
+
+	var zero T
+	n.V, n.Valid = zero, t != TypeNull
+	if !n.Valid {
+		return nil
+	}
+	return r.scan(i, &n.V)
+}
+
+// Scan implements sql.Scanner, so that Null can be a destination of the database/sql driver too. src is converted to
+// T with the same rules as Rows.Scan.
+func (n *Null[T]) Scan(src any) error {
+	var zero T
+	n.V, n.Valid = zero, src != nil
+	if !n.Valid {
+		return nil
+	}
+	if t, ok := src.(time.Time); ok {
+		src = FormatTime(t)
+	}
+	v, err := ValueOf(src)
+	if err != nil {
+		return err
+	}
+	return scanValue(v, &n.V)
+}
+
+// Value implements driver.Valuer, so that Null can be an argument of Stmt.Bind and of the database/sql driver. It
+// returns nil if Valid is false.
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	var v any = n.V
+	if sv, ok := v.(Value); ok {
+		return sv.Any(), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// scanValue is like Rows.scan, but converts v instead of a column.
+func scanValue(v Value, dest any) (err error) {
+	switch d := dest.(type) {
+	case *int:
+		*d, err = int64ToInt(v.Int64())
+	case *int64:
+		*d = v.Int64()
+	case *bool:
+		*d = v.Int64() != 0
+	case *float64:
+		*d = v.Float64()
+	case *float32:
+		*d = float32(v.Float64())
+	case *string:
+		*d = v.Text()
+	case *[]byte:
+		// The driver may reuse the bytes of src.
+		*d = append([]byte(nil), v.Blob()...)
+	case *Value:
+		*d = v
+	case *any:
+		*d = v.Any()
+	case *time.Time:
+		*d, err = timeFromValue(v)
+	case sql.Scanner:
+		err = d.Scan(v.Any())
+	default:
+		err = fmt.Errorf("unsupported destination type %T", dest)
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStrictScan(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, WithStrictScan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	var (
+		i  int64
+		f  float64
+		s  string
+		b  []byte
+		tm time.Time
+		n  Null[int64]
+		v  Value
+	)
+	tests := []struct {
+		expr     string
+		dest     any
+		mismatch bool
+	}{
+		{expr: "1", dest: &i},
+		{expr: "'1'", dest: &i, mismatch: true},
+		{expr: "1.5", dest: &i, mismatch: true},
+		{expr: "NULL", dest: &i, mismatch: true},
+		{expr: "1.5", dest: &f},
+		{expr: "1", dest: &f, mismatch: true},
+		{expr: "'a'", dest: &s},
+		{expr: "x'00'", dest: &s, mismatch: true},
+		{expr: "x'00'", dest: &b},
+		{expr: "'a'", dest: &b, mismatch: true},
+		{expr: "'2024-01-02'", dest: &tm},
+		{expr: "0", dest: &tm},
+		{expr: "x'00'", dest: &tm, mismatch: true},
+		{expr: "NULL", dest: &n},
+		{expr: "1", dest: &n},
+		{expr: "'1'", dest: &n, mismatch: true},
+		{expr: "'1'", dest: &v},
+	}
+	for _, tt := range tests {
+		rows, err := db.Query(ctx, "SELECT "+tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		err = rows.Scan(tt.dest)
+		rows.Close()
+		if mismatch := errors.Is(err, ErrTypeMismatch); mismatch != tt.mismatch {
+			t.Errorf("scanning %s into %T: got error %v", tt.expr, tt.dest, err)
+		} else if !tt.mismatch && err != nil {
+			t.Errorf("scanning %s into %T: %v", tt.expr, tt.dest, err)
+		}
+	}
+}
+
+func TestScanMap(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	rows, err := db.Query(ctx, "SELECT 1 AS i, 1.5 AS f, 'a' AS s, x'00' AS b, NULL AS n UNION ALL SELECT 2, 2.5, 'b', x'01', NULL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	want := []map[string]any{
+		{"i": int64(1), "f": 1.5, "s": "a", "b": []byte{0}, "n": nil},
+		{"i": int64(2), "f": 2.5, "s": "b", "b": []byte{1}, "n": nil},
+	}
+	for _, w := range want {
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		got, err := rows.ScanMap()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("got %v, want %v", got, w)
+		}
+	}
+}
+
+func TestNullBind(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	// quote returns numbers as they are.
+	got, err := queryStrings(t, db, "SELECT quote(?) || '', quote(?), quote(?), quote(?) || ''",
+		Null[int]{V: 1, Valid: true}, Null[string]{V: "a"}, Null[time.Time]{V: at, Valid: true},
+		Null[Value]{V: FloatValue(1.5), Valid: true})
+	if want := []string{"1", "NULL", "'" + FormatTime(at) + "'", "1.5"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestNullScan(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var (
+		i  Null[int]
+		s  Null[string]
+		b  Null[[]byte]
+		tm Null[time.Time]
+	)
+	tests := []struct {
+		src  any
+		dest interface{ Scan(any) error }
+		want any
+	}{
+		{src: int64(1), dest: &i, want: Null[int]{V: 1, Valid: true}},
+		{src: "2", dest: &i, want: Null[int]{V: 2, Valid: true}},
+		{src: nil, dest: &i, want: Null[int]{}},
+		{src: int64(1), dest: &s, want: Null[string]{V: "1", Valid: true}},
+		{src: []byte("a"), dest: &b, want: Null[[]byte]{V: []byte("a"), Valid: true}},
+		{src: FormatTime(at), dest: &tm, want: Null[time.Time]{V: at, Valid: true}},
+		{src: at, dest: &tm, want: Null[time.Time]{V: at, Valid: true}},
+	}
+	for _, tt := range tests {
+		if err := tt.dest.Scan(tt.src); err != nil {
+			t.Errorf("scanning %v into %T: %v", tt.src, tt.dest, err)
+			continue
+		}
+		if got := reflect.ValueOf(tt.dest).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("scanning %v into %T: got %v, want %v", tt.src, tt.dest, got, tt.want)
+		}
+	}
+}
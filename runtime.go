@@ -0,0 +1,91 @@
+package wazerosqlite
+
+import (
+	"context"
+	"path"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Runtime is a single SQLite module instance which can open multiple databases. Unlike DBs created by Open, which
+// each have their own module instance, the databases of a Runtime share the memory of the instance.
+//
+// Note: a Runtime and its DBs must not be used concurrently, as they share the module instance.
+type Runtime struct {
+	// r is the underlying wazero runtime.
+	r wazero.Runtime
+	// m is the module instance all the databases are opened in.
+	m *sqliteModule
+	// c is the configuration the runtime was created with.
+	c *config
+}
+
+// NewRuntime creates a wazero runtime and instantiates SQLite in it. Use WithDir to open database files.
+func NewRuntime(ctx context.Context, opts ...Option) (*Runtime, error) {
+	c := newConfig(opts)
+
+	r, compiledSqlite, _, err := newRuntime(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newSqliteModule(ctx, r, compiledSqlite, c.moduleConfig(), c.abi, c.trapDump)
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	return &Runtime{r: r, m: m, c: c}, nil
+}
+
+// OpenDatabase opens the database file `name` in the directory specified by WithDir, creating it if it doesn't exist.
+// ":memory:" opens a new in-memory database.
+//
+// The returned DB must be closed with DB.Close, which closes only the database but not the Runtime.
+func (rt *Runtime) OpenDatabase(ctx context.Context, name string) (*DB, error) {
+	handle, err := rt.m.openDB(ctx, guestPath(name), openReadWrite|openCreate)
+	if err != nil {
+		return nil, err
+	}
+	db := newDB(rt.m, nopCloser{}, handle, rt.c)
+	if err = db.applyConfig(ctx, rt.c); err != nil {
+		_ = db.Close(ctx)
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the runtime, and therefore all the databases opened in it.
+func (rt *Runtime) Close(ctx context.Context) error {
+	return rt.r.Close(ctx)
+}
+
+// Attach attaches the database file `name` in the mounted directory as the schema, so that its tables can be
+// referred to as schema.table in queries on db, e.g. for joins across databases. ":memory:" attaches a new in-memory
+// database. The file is created if it doesn't exist.
+//
+// The directory is the one of WithFile, or WithDir for the DBs of a Runtime.
+func (db *DB) Attach(ctx context.Context, name, schema string) error {
+	return db.Exec(ctx, "ATTACH DATABASE "+QuoteLiteral(TextValue(guestPath(name)))+" AS "+QuoteIdentifier(schema))
+}
+
+// Detach detaches the schema attached with Attach.
+func (db *DB) Detach(ctx context.Context, schema string) error {
+	return db.Exec(ctx, "DETACH DATABASE "+QuoteIdentifier(schema))
+}
+
+// guestPath returns the path of the file `name` in the mounted directory as seen by the guest.
+func guestPath(name string) string {
+	if name == ":memory:" {
+		return name
+	}
+	// The mounted directory is the root.
+	return path.Join("/", name)
+}
+
+// nopCloser is the api.Closer of the DBs which don't own their module instance.
+type nopCloser struct{}
+
+// Close implements api.Closer.
+func (nopCloser) Close(context.Context) error {
+	return nil
+}
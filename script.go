@@ -0,0 +1,305 @@
+package wazerosqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// StatementResult is the result of a statement executed by ExecScript.
+type StatementResult struct {
+	// SQL is the text of the statement.
+	SQL string
+	// RowsAffected is the number of rows inserted, updated or deleted by the statement, including by triggers.
+	RowsAffected int64
+	// Rows is the number of result rows the statement returned.
+	Rows int64
+	// Columns are the names of the result columns, if the statement returns rows and they are kept.
+	Columns []string
+	// Values are the result rows kept according to the ResultMode, each with a value per column.
+	Values [][]Value
+}
+
+// ResultMode tells ExecScriptMode what to do with the rows returned by the statements of a script, e.g. by pragmas
+// or SELECTs mixed with DDL and DML.
+type ResultMode int
+
+const (
+	// ResultCount counts the rows and discards them.
+	ResultCount ResultMode = iota
+	// ResultFirstRow keeps the first row of each statement, and counts the others.
+	ResultFirstRow
+	// ResultAllRows keeps all the rows.
+	ResultAllRows
+)
+
+// ScriptError is returned by ExecScript when a statement fails.
+type ScriptError struct {
+	// Index is the index of the failed statement in the script, starting from 0.
+	Index int
+	// SQL is the text of the failed statement.
+	SQL string
+	// Err is why the statement failed.
+	Err error
+}
+
+// Error implements error.
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("statement %d failed: %v: %s", e.Index+1, e.Err, e.SQL)
+}
+
+// Unwrap returns Err.
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// ExecScript executes the statements of the script one by one, e.g. a schema migration file, and returns their
+// results. Unlike Exec, which runs the script in a single sqlite3_exec call, it tells what each statement did. Result
+// rows are counted and discarded, see ExecScriptMode to keep them.
+//
+// Execution stops at the first failure, in which case the results of the statements executed so far are returned
+// with a *ScriptError. Statements already executed are not rolled back, unless the script is run in a transaction.
+func (db *DB) ExecScript(ctx context.Context, script string) ([]StatementResult, error) {
+	return db.ExecScriptMode(ctx, script, ResultCount)
+}
+
+// ExecScriptMode is like ExecScript, but keeps the result rows of the statements as told by mode, in Columns and
+// Values of their StatementResult.
+func (db *DB) ExecScriptMode(ctx context.Context, script string, mode ResultMode) ([]StatementResult, error) {
+	var results []StatementResult
+	statements, _ := splitStatements(script)
+	for i, sql := range statements {
+		res, err := db.execStatement(ctx, sql, mode)
+		if err != nil {
+			return results, &ScriptError{Index: i, SQL: sql, Err: err}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// execStatement executes the single statement sql to completion, keeping the rows as told by mode.
+func (db *DB) execStatement(ctx context.Context, sql string, mode ResultMode) (StatementResult, error) {
+	res := StatementResult{SQL: sql}
+	before, err := db.m.callInt(ctx, db.m.totalChanges, "sqlite3_total_changes", uint64(db.handle))
+	if err != nil {
+		return res, err
+	}
+
+	s, err := db.Prepare(ctx, sql)
+	if err != nil {
+		return res, err
+	}
+	defer s.Close(ctx)
+
+	for {
+		hasRow, err := s.Step(ctx)
+		if err != nil {
+			return res, err
+		} else if !hasRow {
+			break
+		}
+		if mode == ResultAllRows || mode == ResultFirstRow && res.Rows == 0 {
+			if err = res.keepRow(ctx, s); err != nil {
+				return res, err
+			}
+		}
+		res.Rows++
+	}
+
+	after, err := db.m.callInt(ctx, db.m.totalChanges, "sqlite3_total_changes", uint64(db.handle))
+	if err != nil {
+		return res, err
+	}
+	res.RowsAffected = int64(after - before)
+	return res, nil
+}
+
+// keepRow appends the current row of s to Values, after reading Columns for the first row.
+func (res *StatementResult) keepRow(ctx context.Context, s *Stmt) error {
+	if res.Columns == nil {
+		n, err := s.ColumnCount(ctx)
+		if err != nil {
+			return err
+		}
+		res.Columns = make([]string, n)
+		for i := range res.Columns {
+			if res.Columns[i], err = s.ColumnName(ctx, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	row := make([]Value, len(res.Columns))
+	for i := range row {
+		v, err := s.ColumnValue(ctx, i)
+		if err != nil {
+			return err
+		}
+		row[i] = v
+	}
+	res.Values = append(res.Values, row)
+	return nil
+}
+
+// SplitStatements splits the script into its statements the way ExecScript does, without the terminating semicolons
+// and leaving out those which are empty or only comments.
+func SplitStatements(script string) []string {
+	statements, _ := splitStatements(script)
+	return statements
+}
+
+// Complete returns true if sql ends with a complete statement, i.e. a semicolon which isn't inside a literal, a
+// comment or the body of CREATE TRIGGER, optionally followed by whitespace and comments, like sqlite3_complete. This
+// tells whether more input is needed, e.g. in an interactive shell.
+func Complete(sql string) bool {
+	statements, complete := splitStatements(sql)
+	return complete && len(statements) > 0
+}
+
+// splitStatements implements SplitStatements, and also returns whether the last statement is terminated.
+//
+// Note: sqlite3_prepare_v2 returns where the next statement starts, but the fluencelabs build doesn't return that
+// tail, so the script is split here instead, with the state machine of sqlite3_complete: inside CREATE TRIGGER, a
+// semicolon only ends the statement if it follows the END keyword which itself follows a semicolon.
+func splitStatements(script string) ([]string, bool) {
+	var statements []string
+	// start and end are the bounds of the tokens of the current statement, which is empty if start is -1.
+	start, end, state := -1, 0, completeStart
+	token := func(i, j int, t completeToken) {
+		if start < 0 {
+			start = i
+		}
+		end = j
+		state = state.next(t)
+	}
+	for i := 0; i < len(script); {
+		if j := skipIgnored(script, i); j > i {
+			// Literals and quoted identifiers are tokens, while comments are whitespace.
+			if c := script[i]; c != '-' && c != '/' {
+				token(i, j, tokenOther)
+			}
+			i = j
+			continue
+		}
+
+		c := script[i]
+		switch {
+		case c == ';':
+			if state.next(tokenSemi) == completeStart {
+				state = completeStart
+				if start >= 0 {
+					statements = append(statements, script[start:end])
+				}
+				start = -1
+			} else {
+				token(i, i+1, tokenSemi)
+			}
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(script) && isIdentifierChar(script[j]) {
+				j++
+			}
+			token(i, j, keywordToken(script[i:j]))
+			i = j
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		default:
+			token(i, i+1, tokenOther)
+			i++
+		}
+	}
+	if start >= 0 {
+		return append(statements, script[start:end]), false
+	}
+	return statements, true
+}
+
+// completeToken is a class of tokens of the sqlite3_complete state machine.
+type completeToken int
+
+const (
+	tokenSemi completeToken = iota
+	tokenOther
+	tokenExplain
+	tokenCreate
+	tokenTemp
+	tokenTrigger
+	tokenEnd
+)
+
+// completeState is a state of the sqlite3_complete state machine. Whitespace and comments don't change the state.
+type completeState int
+
+const (
+	// completeStart is between statements.
+	completeStart completeState = iota
+	// completeNormal is inside a statement.
+	completeNormal
+	// completeExplain is after EXPLAIN at the start of a statement.
+	completeExplain
+	// completeCreate is after CREATE, and optionally TEMP, at the start of a statement.
+	completeCreate
+	// completeTrigger is inside CREATE TRIGGER.
+	completeTrigger
+	// completeSemi is after a semicolon inside CREATE TRIGGER.
+	completeSemi
+	// completeEnd is after END following a semicolon inside CREATE TRIGGER.
+	completeEnd
+)
+
+// next returns the state of the sqlite3_complete state machine after the token.
+func (s completeState) next(t completeToken) completeState {
+	switch s {
+	case completeStart, completeNormal, completeExplain, completeCreate:
+		switch {
+		case t == tokenSemi:
+			return completeStart
+		case s == completeStart && t == tokenExplain:
+			return completeExplain
+		case s == completeExplain && t == tokenOther:
+			// e.g. EXPLAIN QUERY PLAN.
+			return completeExplain
+		case (s == completeStart || s == completeExplain) && t == tokenCreate:
+			return completeCreate
+		case s == completeCreate && t == tokenTemp:
+			return completeCreate
+		case s == completeCreate && t == tokenTrigger:
+			return completeTrigger
+		}
+		return completeNormal
+	case completeTrigger:
+		if t == tokenSemi {
+			return completeSemi
+		}
+	case completeSemi:
+		if t == tokenSemi {
+			return completeSemi
+		} else if t == tokenEnd {
+			return completeEnd
+		}
+	case completeEnd:
+		if t == tokenSemi {
+			return completeStart
+		}
+	}
+	return completeTrigger
+}
+
+// keywordToken returns the class of the word for the sqlite3_complete state machine.
+func keywordToken(word string) completeToken {
+	switch strings.ToUpper(word) {
+	case "EXPLAIN":
+		return tokenExplain
+	case "CREATE":
+		return tokenCreate
+	case "TEMP", "TEMPORARY":
+		return tokenTemp
+	case "TRIGGER":
+		return tokenTrigger
+	case "END":
+		return tokenEnd
+	}
+	return tokenOther
+}
@@ -0,0 +1,134 @@
+package wazerosqlite
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		want     []string
+		complete bool
+	}{
+		{
+			name:     "empty",
+			script:   "  -- nothing\n",
+			complete: true,
+		},
+		{
+			name:     "two statements",
+			script:   "SELECT 1; SELECT 2;",
+			want:     []string{"SELECT 1", "SELECT 2"},
+			complete: true,
+		},
+		{
+			name:   "unterminated",
+			script: "SELECT 1; SELECT 2",
+			want:   []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:     "semicolons in literals and comments",
+			script:   "SELECT ';', \"a;b\", [c;d] /* ; */ -- ;\n;",
+			want:     []string{"SELECT ';', \"a;b\", [c;d]"},
+			complete: true,
+		},
+		{
+			name:     "trigger",
+			script:   "CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT 1; SELECT 2; END; SELECT 3;",
+			want:     []string{"CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT 1; SELECT 2; END", "SELECT 3"},
+			complete: true,
+		},
+		{
+			name:   "CASE END inside trigger",
+			script: "CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT CASE WHEN 1 THEN 2 END; SELECT 3; END;",
+			want: []string{
+				"CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT CASE WHEN 1 THEN 2 END; SELECT 3; END",
+			},
+			complete: true,
+		},
+		{
+			name:   "unterminated trigger",
+			script: "CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT CASE WHEN 1 THEN 2 END;",
+			want:   []string{"CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT CASE WHEN 1 THEN 2 END;"},
+		},
+		{
+			name:     "temp trigger with comment before END",
+			script:   "create temp trigger t after delete on x begin delete from y; /* done */ end;",
+			want:     []string{"create temp trigger t after delete on x begin delete from y; /* done */ end"},
+			complete: true,
+		},
+		{
+			name:     "explain",
+			script:   "EXPLAIN QUERY PLAN SELECT 1; EXPLAIN CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT 1; END;",
+			want:     []string{"EXPLAIN QUERY PLAN SELECT 1", "EXPLAIN CREATE TRIGGER t AFTER INSERT ON x BEGIN SELECT 1; END"},
+			complete: true,
+		},
+		{
+			name:     "END outside trigger",
+			script:   "BEGIN; END;",
+			want:     []string{"BEGIN", "END"},
+			complete: true,
+		},
+		{
+			name:     "table named trigger",
+			script:   "CREATE TABLE trigger (a); SELECT 1;",
+			want:     []string{"CREATE TABLE trigger (a)", "SELECT 1"},
+			complete: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, complete := splitStatements(tt.script)
+			if !reflect.DeepEqual(got, tt.want) || complete != tt.complete {
+				t.Errorf("got %q, %v, want %q, %v", got, complete, tt.want, tt.complete)
+			}
+			if c := Complete(tt.script); c != (tt.complete && len(tt.want) > 0) {
+				t.Errorf("Complete returned %v", c)
+			}
+		})
+	}
+}
+
+func TestExecScriptMode(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	const script = "CREATE TABLE t (a, b); INSERT INTO t VALUES (1, 'x'), (2, 'y'); SELECT a, b FROM t ORDER BY a;"
+	tests := []struct {
+		mode ResultMode
+		want [][]Value
+	}{
+		{mode: ResultCount},
+		{mode: ResultFirstRow, want: [][]Value{{IntegerValue(1), TextValue("x")}}},
+		{mode: ResultAllRows, want: [][]Value{{IntegerValue(1), TextValue("x")}, {IntegerValue(2), TextValue("y")}}},
+	}
+	for _, tt := range tests {
+		if err = db.Exec(ctx, "DROP TABLE IF EXISTS t"); err != nil {
+			t.Fatal(err)
+		}
+		results, err := db.ExecScriptMode(ctx, script, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 3 {
+			t.Fatalf("got %d results, want 3", len(results))
+		}
+		if results[1].RowsAffected != 2 || results[1].Values != nil {
+			t.Errorf("mode %d: INSERT result is %+v", tt.mode, results[1])
+		}
+		selected := results[2]
+		if selected.Rows != 2 || !reflect.DeepEqual(selected.Values, tt.want) {
+			t.Errorf("mode %d: got %d rows with %v, want 2 rows with %v", tt.mode, selected.Rows, selected.Values, tt.want)
+		}
+		if tt.want != nil && !reflect.DeepEqual(selected.Columns, []string{"a", "b"}) {
+			t.Errorf("mode %d: got columns %v", tt.mode, selected.Columns)
+		}
+	}
+}
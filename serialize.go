@@ -0,0 +1,70 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errNoDir is returned when an operation needs a mounted directory but the DB has none.
+var errNoDir = errors.New("no directory is mounted: use WithFile or WithDir")
+
+// Serialize returns the content of the database as the bytes of a database file, e.g. to be loaded later with
+// OpenFromBytes.
+//
+// Note: the Wasm build of SQLite is compiled without sqlite3_serialize, so the database is copied with Backup to a
+// temporary file in the mounted directory, which is therefore required even for in-memory databases.
+func (db *DB) Serialize(ctx context.Context) ([]byte, error) {
+	if db.dir == "" {
+		return nil, errNoDir
+	}
+
+	f, err := os.CreateTemp(db.dir, ".serialize-*")
+	if err != nil {
+		return nil, err
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	if err = db.Backup(ctx, filepath.Base(path)); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// OpenFromBytes is like Open, but opens a copy of the database file `data`, e.g. a prebuilt database embedded with
+// go:embed. Changes are made to the copy, which is discarded on DB.Close.
+//
+// Note: the Wasm build of SQLite is compiled without sqlite3_deserialize, so the copy is a file in a temporary
+// directory rather than an in-memory database. WithFile in opts is overridden.
+//
+// ErrNotDatabase is returned if data doesn't start with the header of a database file. Empty data opens an empty
+// database, as SQLite does with an empty file.
+func OpenFromBytes(ctx context.Context, data []byte, opts ...Option) (*DB, error) {
+	if len(data) > 0 {
+		if _, err := ParseHeader(data); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "wazero-sqlite-*")
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "main.db")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	db, err := Open(ctx, append(opts[:len(opts):len(opts)], WithFile(path))...)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	db.tempDir = dir
+	return db, nil
+}
@@ -0,0 +1,121 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSerializeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := Open(ctx, WithDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	if err = db.Exec(ctx, "CREATE TABLE t (a); INSERT INTO t VALUES ('x'), ('y')"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := db.Serialize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseHeader(data); err != nil {
+		t.Fatalf("serialized bytes: %v", err)
+	}
+	// The temporary copy is removed.
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 0 {
+		t.Errorf("left in the directory: %v, %v", entries, err)
+	}
+
+	// values opens a copy of data and returns the values in t.
+	values := func(data []byte) string {
+		t.Helper()
+		db, err := OpenFromBytes(ctx, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close(ctx)
+		got, err := queryStrings(t, db, "SELECT group_concat(a) FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got[0]
+	}
+	if got := values(data); got != "x,y" {
+		t.Errorf("got %q", got)
+	}
+
+	// Changes to the copy don't affect the bytes, and the copy can be serialized again.
+	copied, err := OpenFromBytes(ctx, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close(ctx)
+	if err = copied.Exec(ctx, "INSERT INTO t VALUES ('z')"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := copied.Serialize(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := values(data); got != "x,y" {
+		t.Errorf("original bytes: got %q", got)
+	}
+	if got := values(again); got != "x,y,z" {
+		t.Errorf("serialized copy: got %q", got)
+	}
+}
+
+func TestSerializeNoDir(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	if _, err = db.Serialize(ctx); !errors.Is(err, errNoDir) {
+		t.Errorf("got %v, want errNoDir", err)
+	}
+}
+
+func TestOpenFromBytesInvalid(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	for _, data := range [][]byte{[]byte("not a database"), make([]byte, 4096)} {
+		if _, err := OpenFromBytes(context.Background(), data); !errors.Is(err, ErrNotDatabase) {
+			t.Errorf("got %v, want ErrNotDatabase", err)
+		}
+	}
+	if entries, err := os.ReadDir(tmp); err != nil || len(entries) != 0 {
+		t.Errorf("temporary files left: %v, %v", entries, err)
+	}
+}
+
+func TestOpenFromBytesRemovesTempDir(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	// Empty bytes are an empty database.
+	db, err := OpenFromBytes(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Exec(ctx, "CREATE TABLE t (a)"); err != nil {
+		t.Fatal(err)
+	}
+	dir := db.tempDir
+	if _, err = os.Stat(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary directory left after Close: %v", err)
+	}
+}
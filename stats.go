@@ -0,0 +1,87 @@
+package wazerosqlite
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slowestReported is the number of the slowest statements reported by WithCloseStats.
+const slowestReported = 5
+
+// closeStats are the statistics of a DB collected for WithCloseStats.
+type closeStats struct {
+	// prepared is the number of statements prepared with DB.Prepare, including those of DB.Query.
+	prepared int64
+	// execs is the number of DB.Exec calls.
+	execs int64
+	// errors are the number of errors by result code.
+	errors map[ErrorCode]int64
+	// durations are the total time spent executing each statement, keyed by its fingerprint.
+	durations map[string]time.Duration
+}
+
+func newCloseStats() *closeStats {
+	return &closeStats{errors: map[ErrorCode]int64{}, durations: map[string]time.Duration{}}
+}
+
+// record adds the execution of the query which took d and failed with err, if not nil. It does nothing if s is nil,
+// i.e. WithCloseStats isn't given.
+func (s *closeStats) record(query string, d time.Duration, err error) {
+	if s == nil {
+		return
+	}
+	s.durations[fingerprint(query)] += d
+	var e *Error
+	if errors.As(err, &e) {
+		s.errors[e.Code]++
+	}
+}
+
+// fingerprint returns query with its whitespace collapsed and truncated, so that the same statement written on
+// several lines is counted once. Parameters are bound, so the text doesn't vary with the values.
+func fingerprint(query string) string {
+	s := strings.Join(strings.Fields(query), " ")
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return s
+}
+
+// summary returns the statistics as a single line, with memorySize the size of the guest memory in bytes.
+func (s *closeStats) summary(memorySize uint32) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d statements prepared, %d executed, guest memory %d KiB", s.prepared, s.execs, memorySize/1024)
+
+	codes := make([]ErrorCode, 0, len(s.errors))
+	for c := range s.errors {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for i, c := range codes {
+		if i == 0 {
+			b.WriteString(", errors:")
+		}
+		fmt.Fprintf(&b, " %s=%d", c, s.errors[c])
+	}
+
+	queries := make([]string, 0, len(s.durations))
+	for q := range s.durations {
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool { return s.durations[queries[i]] > s.durations[queries[j]] })
+	if len(queries) > slowestReported {
+		queries = queries[:slowestReported]
+	}
+	for i, q := range queries {
+		if i == 0 {
+			b.WriteString(", slowest:")
+		} else {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %s %q", s.durations[q].Round(time.Microsecond), q)
+	}
+	return b.String()
+}
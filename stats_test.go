@@ -0,0 +1,36 @@
+package wazerosqlite
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloseStatsSummary(t *testing.T) {
+	s := newCloseStats()
+	s.prepared, s.execs = 3, 1
+	s.record("SELECT *\n  FROM t", 2*time.Millisecond, nil)
+	s.record("SELECT * FROM t", time.Millisecond, nil)
+	s.record("INSERT INTO t VALUES (?)", time.Millisecond, newError(19, "UNIQUE constraint failed: t.a", ""))
+	s.record("INSERT INTO t VALUES (?)", time.Millisecond, newError(19, "UNIQUE constraint failed: t.a", ""))
+
+	want := `3 statements prepared, 1 executed, guest memory 64 KiB, errors: SQLITE_CONSTRAINT=2, ` +
+		`slowest: 3ms "SELECT * FROM t"; 2ms "INSERT INTO t VALUES (?)"`
+	if got := s.summary(64 * 1024); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A nil closeStats, i.e. without WithCloseStats, records nothing.
+	var nilStats *closeStats
+	nilStats.record("SELECT 1", time.Second, nil)
+}
+
+func TestFingerprint(t *testing.T) {
+	if got := fingerprint(" SELECT  a,\n\tb FROM t "); got != "SELECT a, b FROM t" {
+		t.Errorf("got %q", got)
+	}
+	long := "SELECT " + strings.Repeat("a, ", 50) + "b"
+	if got := fingerprint(long); len(got) != 80 || !strings.HasSuffix(got, "...") {
+		t.Errorf("long query isn't truncated: %q", got)
+	}
+}
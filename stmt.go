@@ -0,0 +1,301 @@
+package wazerosqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ErrOverflow is returned when an integer doesn't fit in the type it is bound from or scanned into, e.g. a uint64
+// above math.MaxInt64, as SQLite integers are signed 64-bit.
+var ErrOverflow = errors.New("integer out of range")
+
+// Stmt is a prepared statement created by DB.Prepare.
+type Stmt struct {
+	// db is the database this statement was prepared on.
+	db *DB
+	// handle is the pointer to sqlite3_stmt in the guest memory, or zero after Close.
+	handle uint32
+	// query is the SQL this statement was prepared from.
+	query string
+	// params maps the named parameters in query to their indexes, parsed on the first BindNamed.
+	params map[string]int
+	// anonymous are the indexes of the "?" and "?NNN" parameters in query, parsed with params.
+	anonymous []int
+}
+
+// Step evaluates the statement until the next result row is available, and returns false when the statement has
+// run to completion.
+//
+// Step returns ctx.Err() if ctx is done, so that long-running queries can be cancelled between rows. A single step
+// can't be interrupted, as sqlite3_interrupt isn't exported by the module.
+func (s *Stmt) Step(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var start time.Time
+	if s.db.stats != nil {
+		start = time.Now()
+	}
+	rc, err := s.db.m.execStep(ctx, s.handle)
+	if err != nil {
+		return false, err
+	}
+
+	if rc != sqliteRow && rc != sqliteDone {
+		err = s.db.error(ctx, rc, s.query)
+	}
+	if s.db.stats != nil {
+		s.db.stats.record(s.query, time.Since(start), err)
+	}
+	return rc == sqliteRow, err
+}
+
+// ColumnInt64 returns the i-th column of the current row as an integer.
+func (s *Stmt) ColumnInt64(ctx context.Context, i int) (int64, error) {
+	return s.db.m.readInt(ctx, s.handle, uint32(i))
+}
+
+// ColumnFloat64 returns the i-th column of the current row as a floating point number.
+func (s *Stmt) ColumnFloat64(ctx context.Context, i int) (float64, error) {
+	return s.db.m.readDouble(ctx, s.handle, uint32(i))
+}
+
+// ColumnText returns the i-th column of the current row as a string.
+//
+// See WithInvalidUTF8 for how text which isn't valid UTF-8 is handled.
+func (s *Stmt) ColumnText(ctx context.Context, i int) (string, error) {
+	text, err := s.db.m.readText(ctx, s.handle, uint32(i))
+	if err != nil {
+		return "", err
+	}
+	return s.db.invalidUTF8.apply(text)
+}
+
+// ColumnType is the storage class of a column value.
+type ColumnType int
+
+const (
+	// TypeInteger is SQLITE_INTEGER.
+	TypeInteger ColumnType = 1
+	// TypeFloat is SQLITE_FLOAT.
+	TypeFloat ColumnType = 2
+	// TypeText is SQLITE_TEXT.
+	TypeText ColumnType = 3
+	// TypeBlob is SQLITE_BLOB.
+	TypeBlob ColumnType = 4
+	// TypeNull is SQLITE_NULL.
+	TypeNull ColumnType = 5
+)
+
+// String implements fmt.Stringer.
+func (t ColumnType) String() string {
+	switch t {
+	case TypeInteger:
+		return "INTEGER"
+	case TypeFloat:
+		return "FLOAT"
+	case TypeText:
+		return "TEXT"
+	case TypeBlob:
+		return "BLOB"
+	case TypeNull:
+		return "NULL"
+	}
+	return fmt.Sprintf("ColumnType(%d)", int(t))
+}
+
+// ColumnCount returns the number of columns in the result set of the statement.
+func (s *Stmt) ColumnCount(ctx context.Context) (int, error) {
+	return s.db.m.callInt(ctx, s.db.m.columnCount, "sqlite3_column_count", uint64(s.handle))
+}
+
+// ColumnName returns the name of the i-th column in the result set.
+func (s *Stmt) ColumnName(ctx context.Context, i int) (string, error) {
+	return s.db.m.readColumnName(ctx, s.handle, uint32(i))
+}
+
+// ColumnType returns the storage class of the i-th column of the current row.
+func (s *Stmt) ColumnType(ctx context.Context, i int) (ColumnType, error) {
+	t, err := s.db.m.callInt(ctx, s.db.m.columnType, "sqlite3_column_type", uint64(s.handle), uint64(i))
+	return ColumnType(t), err
+}
+
+// ColumnBlob returns the i-th column of the current row as a byte slice.
+func (s *Stmt) ColumnBlob(ctx context.Context, i int) ([]byte, error) {
+	return s.db.m.readBlob(ctx, s.handle, uint32(i))
+}
+
+// ColumnValue returns the i-th column of the current row as a Value of its storage class.
+func (s *Stmt) ColumnValue(ctx context.Context, i int) (Value, error) {
+	t, err := s.ColumnType(ctx, i)
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch t {
+	case TypeInteger:
+		v, err := s.ColumnInt64(ctx, i)
+		return IntegerValue(v), err
+	case TypeFloat:
+		v, err := s.ColumnFloat64(ctx, i)
+		return FloatValue(v), err
+	case TypeText:
+		v, err := s.ColumnText(ctx, i)
+		return TextValue(v), err
+	case TypeBlob:
+		v, err := s.ColumnBlob(ctx, i)
+		return BlobValue(v), err
+	case TypeNull:
+		return NullValue(), nil
+	default:
+		return Value{}, fmt.Errorf("unsupported type %s of column %d", t, i)
+	}
+}
+
+// Bind binds v to the parameter at index, which starts from 1 as in SQLite.
+//
+// Supported types are nil, Value, signed and unsigned integers, bool, float64, float32, string, []byte, time.Time and
+// driver.Valuer such as Null, whose value is bound instead. time.Time is stored as text in the format understood by
+// SQLite's date and time functions. Unsigned integers above math.MaxInt64 aren't silently wrapped to negative values:
+// ErrOverflow is returned instead.
+func (s *Stmt) Bind(ctx context.Context, index int, v any) error {
+	var rc int
+	var err error
+	switch v := v.(type) {
+	case nil:
+		rc, err = s.db.m.callInt(ctx, s.db.m.bindNull, "sqlite3_bind_null", uint64(s.handle), uint64(index))
+	case Value:
+		return s.Bind(ctx, index, v.Any())
+	case int:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case int8:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case int16:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case int32:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case int64:
+		rc, err = s.bindInt64(ctx, index, v)
+	case uint8:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case uint16:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case uint32:
+		rc, err = s.bindInt64(ctx, index, int64(v))
+	case uint:
+		return s.Bind(ctx, index, uint64(v))
+	case uint64:
+		i, overflow := uint64ToInt64(v)
+		if overflow != nil {
+			return fmt.Errorf("failed to bind parameter %d: %w", index, overflow)
+		}
+		rc, err = s.bindInt64(ctx, index, i)
+	case bool:
+		var i int64
+		if v {
+			i = 1
+		}
+		rc, err = s.bindInt64(ctx, index, i)
+	case float64:
+		rc, err = s.bindFloat64(ctx, index, v)
+	case float32:
+		rc, err = s.bindFloat64(ctx, index, float64(v))
+	case string:
+		rc, err = s.db.m.bindBytes(ctx, s.db.m.bindText, "sqlite3_bind_text", s.handle, index, []byte(v))
+	case []byte:
+		if v == nil {
+			rc, err = s.db.m.callInt(ctx, s.db.m.bindNull, "sqlite3_bind_null", uint64(s.handle), uint64(index))
+		} else {
+			rc, err = s.db.m.bindBytes(ctx, s.db.m.bindBlob, "sqlite3_bind_blob", s.handle, index, v)
+		}
+	case time.Time:
+		text := FormatTime(v)
+		rc, err = s.db.m.bindBytes(ctx, s.db.m.bindText, "sqlite3_bind_text", s.handle, index, []byte(text))
+	case driver.Valuer:
+		dv, valueErr := v.Value()
+		if valueErr != nil {
+			return fmt.Errorf("failed to bind parameter %d: %w", index, valueErr)
+		}
+		return s.Bind(ctx, index, dv)
+	default:
+		return fmt.Errorf("unsupported type %T for parameter %d", v, index)
+	}
+
+	if err != nil {
+		return err
+	} else if rc != sqliteOK {
+		return fmt.Errorf("failed to bind parameter %d: %w", index, s.db.error(ctx, rc, s.query))
+	}
+	return nil
+}
+
+// uint64ToInt64 converts v to int64, or returns ErrOverflow if it is above math.MaxInt64.
+func uint64ToInt64(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %d exceeds the range of int64", ErrOverflow, v)
+	}
+	return int64(v), nil
+}
+
+// int64ToInt converts v to int, or returns ErrOverflow if it doesn't fit, which only happens on 32-bit platforms.
+func int64ToInt(v int64) (int, error) {
+	if v < math.MinInt || v > math.MaxInt {
+		return 0, fmt.Errorf("%w: %d exceeds the range of int", ErrOverflow, v)
+	}
+	return int(v), nil
+}
+
+func (s *Stmt) bindInt64(ctx context.Context, index int, v int64) (int, error) {
+	return s.db.m.callInt(ctx, s.db.m.bindInt, "sqlite3_bind_int64", uint64(s.handle), uint64(index), uint64(v))
+}
+
+func (s *Stmt) bindFloat64(ctx context.Context, index int, v float64) (int, error) {
+	return s.db.m.callInt(ctx, s.db.m.bindDouble, "sqlite3_bind_double", uint64(s.handle), uint64(index), api.EncodeF64(v))
+}
+
+// Reset resets the statement so that it can be executed again. Bound parameters are retained.
+func (s *Stmt) Reset(ctx context.Context) error {
+	rc, err := s.db.m.callInt(ctx, s.db.m.reset, "sqlite3_reset", uint64(s.handle))
+	if err != nil {
+		return err
+	} else if rc != sqliteOK {
+		return s.db.error(ctx, rc, s.query)
+	}
+	return nil
+}
+
+// Close destroys the statement via sqlite3_finalize. The statement must not be used afterwards, but calling Close
+// again is a no-op.
+func (s *Stmt) Close(ctx context.Context) error {
+	if s.handle == 0 {
+		return nil
+	}
+	runtime.SetFinalizer(s, nil)
+	handle := s.handle
+	s.handle = 0
+	delete(s.db.stmts, handle)
+
+	rc, err := s.db.m.callInt(ctx, s.db.m.finalize, "sqlite3_finalize", uint64(handle))
+	if err != nil {
+		return err
+	} else if rc != sqliteOK {
+		return s.db.error(ctx, rc, s.query)
+	}
+	return nil
+}
+
+// reportLeakedStmt is set as the finalizer of Stmt to log statements which were garbage collected without Close.
+//
+// The statement can't be finalized here, as the finalizer runs concurrently with the users of the module instance.
+// Instead, DB.Close finalizes all the statements left open.
+func reportLeakedStmt(s *Stmt) {
+	s.db.logger.Printf("wazerosqlite: statement was not closed: %s", s.query)
+}
@@ -0,0 +1,117 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestUint64ToInt64(t *testing.T) {
+	tests := []struct {
+		in       uint64
+		want     int64
+		overflow bool
+	}{
+		{in: 0, want: 0},
+		{in: 1, want: 1},
+		{in: math.MaxInt64, want: math.MaxInt64},
+		{in: math.MaxInt64 + 1, overflow: true},
+		{in: math.MaxUint64, overflow: true},
+	}
+	for _, tt := range tests {
+		got, err := uint64ToInt64(tt.in)
+		if tt.overflow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Errorf("uint64ToInt64(%d): got error %v, want ErrOverflow", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("uint64ToInt64(%d) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestInt64ToInt(t *testing.T) {
+	tests := []struct {
+		in       int64
+		overflow bool
+	}{
+		{in: 0},
+		{in: math.MinInt},
+		{in: math.MaxInt},
+		{in: math.MinInt32},
+		{in: math.MaxInt32},
+		{in: math.MaxInt32 + 1, overflow: math.MaxInt == math.MaxInt32},
+		{in: math.MinInt32 - 1, overflow: math.MaxInt == math.MaxInt32},
+		{in: math.MinInt64, overflow: math.MaxInt == math.MaxInt32},
+		{in: math.MaxInt64, overflow: math.MaxInt == math.MaxInt32},
+	}
+	for _, tt := range tests {
+		got, err := int64ToInt(tt.in)
+		if tt.overflow {
+			if !errors.Is(err, ErrOverflow) {
+				t.Errorf("int64ToInt(%d): got error %v, want ErrOverflow", tt.in, err)
+			}
+			continue
+		}
+		if err != nil || int64(got) != tt.in {
+			t.Errorf("int64ToInt(%d) = %d, %v, want %d", tt.in, got, err, tt.in)
+		}
+	}
+}
+
+func TestValueOfUnsigned(t *testing.T) {
+	v, err := ValueOf(uint64(math.MaxInt64))
+	if err != nil || v.Int64() != math.MaxInt64 {
+		t.Errorf("ValueOf(MaxInt64) = %v, %v", v, err)
+	}
+	if _, err = ValueOf(uint64(math.MaxUint64)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("ValueOf(MaxUint64): got error %v, want ErrOverflow", err)
+	}
+}
+
+func TestBindInt64Boundaries(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+
+	tests := []struct {
+		name string
+		in   any
+		want int64
+	}{
+		{name: "MinInt64", in: int64(math.MinInt64), want: math.MinInt64},
+		{name: "MaxInt64", in: int64(math.MaxInt64), want: math.MaxInt64},
+		{name: "MaxInt64 as uint64", in: uint64(math.MaxInt64), want: math.MaxInt64},
+		{name: "MaxUint32", in: uint32(math.MaxUint32), want: math.MaxUint32},
+		{name: "MinInt8", in: int8(math.MinInt8), want: math.MinInt8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.Query(ctx, "SELECT ?", tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got int64
+			if !rows.Next() {
+				t.Fatal(rows.Err())
+			}
+			if err = rows.Scan(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err = db.Query(ctx, "SELECT ?", uint64(math.MaxInt64+1)); !errors.Is(err, ErrOverflow) {
+		t.Errorf("binding MaxInt64+1: got error %v, want ErrOverflow", err)
+	}
+}
@@ -0,0 +1,125 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// timeFormat is the format time.Time is bound with. SQLite's date and time functions accept it as-is.
+const timeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// timeParseFormats are the time value formats of SQLite's date and time functions, in the order ParseTime tries them.
+// See https://www.sqlite.org/lang_datefunc.html#time_values
+//
+// Note: Go accepts fractional seconds after the seconds field even if the layout doesn't have them.
+var timeParseFormats = []string{
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// julianDayUnixEpoch is the Julian day number of 1970-01-01 00:00:00 UTC.
+const julianDayUnixEpoch = 2440587.5
+
+// FormatTime formats t in the same way as time.Time is bound as a parameter. The result is understood by SQLite's
+// date and time functions.
+func FormatTime(t time.Time) string {
+	return t.Format(timeFormat)
+}
+
+// ParseTime parses s in any of the formats accepted by SQLite's date and time functions, e.g. the results of
+// datetime(). Times without a timezone are in UTC as in SQLite.
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, format := range timeParseFormats {
+		if t, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time value %q", s)
+}
+
+// JulianDay returns t as the fractional Julian day number like julianday(), with millisecond precision.
+func JulianDay(t time.Time) float64 {
+	return float64(t.UnixMilli())/float64(24*time.Hour/time.Millisecond) + julianDayUnixEpoch
+}
+
+// TimeFromJulianDay converts the Julian day number, e.g. the result of julianday(), to time.Time in UTC with
+// millisecond precision.
+func TimeFromJulianDay(jd float64) time.Time {
+	ms := math.Round((jd - julianDayUnixEpoch) * float64(24*time.Hour/time.Millisecond))
+	return time.UnixMilli(int64(ms)).UTC()
+}
+
+// errInvalidTime is returned when SQLite's date and time functions return NULL.
+var errInvalidTime = errors.New("invalid time value, format or modifier")
+
+// Strftime evaluates strftime(format, t, modifiers...) in SQLite, so that the result is exactly the same as in SQL.
+//
+//	// The first day of the next month.
+//	s, err := db.Strftime(ctx, "%Y-%m-%d", now, "start of month", "+1 month")
+func (db *DB) Strftime(ctx context.Context, format string, t time.Time, modifiers ...string) (string, error) {
+	args := make([]any, 0, len(modifiers)+2)
+	args = append(args, format, t.UTC().Format("2006-01-02 15:04:05.000"))
+	for _, m := range modifiers {
+		args = append(args, m)
+	}
+	query := "SELECT strftime(?, ?" + strings.Repeat(", ?", len(modifiers)) + ")"
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = errInvalidTime
+		}
+		return "", err
+	}
+
+	var v Value
+	if err = rows.Scan(&v); err != nil {
+		return "", err
+	} else if v.IsNull() {
+		return "", errInvalidTime
+	}
+	return v.Text(), nil
+}
+
+// Time applies the modifiers to t with SQLite's date and time functions, e.g. "start of day" or "+7 days", and
+// returns the result in UTC with millisecond precision.
+func (db *DB) Time(ctx context.Context, t time.Time, modifiers ...string) (time.Time, error) {
+	s, err := db.Strftime(ctx, "%Y-%m-%d %H:%M:%f", t, modifiers...)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ParseTime(s)
+}
+
+// timeFromValue converts v to time.Time: TEXT is parsed by ParseTime, INTEGER is seconds since the Unix epoch, and
+// REAL is a Julian day number. These are the three representations understood by SQLite.
+func timeFromValue(v Value) (time.Time, error) {
+	switch v.Type() {
+	case TypeText:
+		return ParseTime(v.Text())
+	case TypeInteger:
+		return time.Unix(v.Int64(), 0).UTC(), nil
+	case TypeFloat:
+		return TimeFromJulianDay(v.Float64()), nil
+	case TypeNull:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %s to time.Time", v.Type())
+}
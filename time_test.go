@@ -0,0 +1,153 @@
+package wazerosqlite
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestFormatTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{
+			name: "UTC",
+			in:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			want: "2024-01-02 03:04:05+00:00",
+		},
+		{
+			name: "positive offset",
+			in:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", 9*60*60)),
+			want: "2024-01-02 03:04:05+09:00",
+		},
+		{
+			name: "negative offset with minutes",
+			in:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("", -(3*60+30)*60)),
+			want: "2024-01-02 03:04:05-03:30",
+		},
+		{
+			name: "milliseconds",
+			in:   time.Date(2024, 1, 2, 3, 4, 5, 120_000_000, time.UTC),
+			want: "2024-01-02 03:04:05.12+00:00",
+		},
+		{
+			name: "nanoseconds",
+			in:   time.Date(2024, 1, 2, 3, 4, 5, 123_456_789, time.UTC),
+			want: "2024-01-02 03:04:05.123456789+00:00",
+		},
+		{
+			name: "before spring forward",
+			in:   time.Date(2024, 3, 10, 1, 59, 59, 0, newYork),
+			want: "2024-03-10 01:59:59-05:00",
+		},
+		{
+			name: "after spring forward",
+			in:   time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+			want: "2024-03-10 03:00:00-04:00",
+		},
+		{
+			name: "first 1:30 of fall back",
+			in:   time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC).In(newYork),
+			want: "2024-11-03 01:30:00-04:00",
+		},
+		{
+			name: "second 1:30 of fall back",
+			in:   time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC).In(newYork),
+			want: "2024-11-03 01:30:00-05:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatTime(tt.in)
+			if got != tt.want {
+				t.Errorf("FormatTime = %q, want %q", got, tt.want)
+			}
+			parsed, err := ParseTime(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !parsed.Equal(tt.in) {
+				t.Errorf("ParseTime(%q) = %v, want %v", got, parsed, tt.in)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{in: "2024-01-02", want: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{in: "2024-01-02 03:04", want: time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)},
+		{in: "2024-01-02T03:04", want: time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)},
+		{in: "2024-01-02 03:04:05", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{in: "2024-01-02 03:04:05.678", want: time.Date(2024, 1, 2, 3, 4, 5, 678_000_000, time.UTC)},
+		{in: "2024-01-02T03:04:05Z", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{in: " 2024-01-02 03:04:05+02:00 ", want: time.Date(2024, 1, 2, 1, 4, 5, 0, time.UTC)},
+		{in: "2024-01-02 03:04-01:30", want: time.Date(2024, 1, 2, 4, 34, 0, 0, time.UTC)},
+		{in: "2024-01-02T03:04:05.5-08:00", want: time.Date(2024, 1, 2, 11, 4, 5, 500_000_000, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTime(tt.in)
+		if err != nil {
+			t.Errorf("ParseTime(%q): %v", tt.in, err)
+		} else if !got.Equal(tt.want) {
+			t.Errorf("ParseTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "now", "2024-13-01", "2024-01-02 25:00", "01/02/2024"} {
+		if _, err := ParseTime(in); err == nil {
+			t.Errorf("ParseTime(%q) succeeded", in)
+		}
+	}
+}
+
+func TestJulianDay(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want float64
+	}{
+		{in: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), want: 2440587.5},
+		{in: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC), want: 2451545},
+		// The same instant in another zone has the same Julian day number.
+		{in: time.Date(2000, 1, 1, 21, 0, 0, 0, time.FixedZone("", 9*60*60)), want: 2451545},
+	}
+	for _, tt := range tests {
+		if got := JulianDay(tt.in); got != tt.want {
+			t.Errorf("JulianDay(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	// Round trips keep millisecond precision.
+	in := time.Date(2024, 3, 10, 7, 30, 15, 250_000_000, time.UTC)
+	if got := TimeFromJulianDay(JulianDay(in)); !got.Equal(in) {
+		t.Errorf("TimeFromJulianDay(JulianDay(%v)) = %v", in, got)
+	}
+}
+
+func TestTimeFromValue(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, v := range []Value{
+		TextValue("2024-01-02 03:04:05"),
+		IntegerValue(want.Unix()),
+		FloatValue(JulianDay(want)),
+	} {
+		got, err := timeFromValue(v)
+		if err != nil {
+			t.Errorf("timeFromValue(%v): %v", v, err)
+		} else if !got.Equal(want) {
+			t.Errorf("timeFromValue(%v) = %v, want %v", v, got, want)
+		}
+	}
+	if got, err := timeFromValue(NullValue()); err != nil || !got.IsZero() {
+		t.Errorf("timeFromValue(NULL) = %v, %v", got, err)
+	}
+}
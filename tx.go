@@ -0,0 +1,108 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTxDone is returned when a Tx or a Savepoint is used after it has been committed, released or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// Tx is a transaction started by DB.Begin. All the statements executed on the DB until Commit or Rollback belong to
+// the transaction.
+type Tx struct {
+	// db is the database the transaction is running on.
+	db *DB
+	// done is true once Commit or Rollback has succeeded.
+	done bool
+}
+
+// Begin starts a transaction.
+func (db *DB) Begin(ctx context.Context) (*Tx, error) {
+	if err := db.Exec(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	return &Tx{db: db}, nil
+}
+
+// Exec executes the query in the transaction. See DB.Exec.
+func (tx *Tx) Exec(ctx context.Context, query string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	return tx.db.Exec(ctx, query)
+}
+
+// Query executes the query in the transaction. See DB.Query.
+func (tx *Tx) Query(ctx context.Context, query string, args ...any) (*Rows, error) {
+	if tx.done {
+		return nil, ErrTxDone
+	}
+	return tx.db.Query(ctx, query, args...)
+}
+
+// Commit commits the transaction. If COMMIT fails, e.g. because the database is busy, the transaction is still open
+// and can be committed again or rolled back.
+func (tx *Tx) Commit(ctx context.Context) error {
+	return tx.end(ctx, "COMMIT")
+}
+
+// Rollback aborts the transaction. It is a no-op returning ErrTxDone if the transaction has already ended, so that
+// it can be deferred right after Begin.
+func (tx *Tx) Rollback(ctx context.Context) error {
+	return tx.end(ctx, "ROLLBACK")
+}
+
+func (tx *Tx) end(ctx context.Context, query string) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if err := tx.db.Exec(ctx, query); err != nil {
+		return err
+	}
+	tx.done = true
+	return nil
+}
+
+// Savepoint is a named point in a transaction which can be rolled back to without aborting the whole transaction.
+type Savepoint struct {
+	// tx is the transaction this savepoint belongs to.
+	tx *Tx
+	// name is the name of the savepoint.
+	name string
+	// done is true once Release or Rollback has succeeded.
+	done bool
+}
+
+// Savepoint starts a savepoint with the name in the transaction. Savepoints can be nested.
+func (tx *Tx) Savepoint(ctx context.Context, name string) (*Savepoint, error) {
+	if err := tx.Exec(ctx, "SAVEPOINT "+QuoteIdentifier(name)); err != nil {
+		return nil, err
+	}
+	return &Savepoint{tx: tx, name: name}, nil
+}
+
+// Release keeps the changes made since the savepoint and removes it, as well as any savepoint nested in it.
+func (sp *Savepoint) Release(ctx context.Context) error {
+	if sp.done {
+		return ErrTxDone
+	}
+	if err := sp.tx.Exec(ctx, "RELEASE "+QuoteIdentifier(sp.name)); err != nil {
+		return err
+	}
+	sp.done = true
+	return nil
+}
+
+// Rollback discards the changes made since the savepoint and removes it. The transaction stays open.
+func (sp *Savepoint) Rollback(ctx context.Context) error {
+	if sp.done {
+		return ErrTxDone
+	}
+	name := QuoteIdentifier(sp.name)
+	if err := sp.tx.Exec(ctx, "ROLLBACK TO "+name+"; RELEASE "+name); err != nil {
+		return err
+	}
+	sp.done = true
+	return nil
+}
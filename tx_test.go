@@ -0,0 +1,199 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// openTestTable opens an in-memory database with a table t of unique values.
+func openTestTable(t *testing.T) *DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := Open(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close(ctx) })
+	if err = db.Exec(ctx, "CREATE TABLE t (a UNIQUE)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// assertValues checks the values in the table t, in insertion order, as returned by group_concat.
+func assertValues(t *testing.T, db *DB, want string) {
+	t.Helper()
+	got, err := queryStrings(t, db, "SELECT coalesce(group_concat(a), '') FROM (SELECT a FROM t ORDER BY rowid)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != want {
+		t.Errorf("got values %q, want %q", got[0], want)
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	ctx := context.Background()
+	db := openTestTable(t)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (1); INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, db, "1,2")
+
+	// The transaction can't be used once committed.
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (3)"); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Exec: got %v, want ErrTxDone", err)
+	}
+	if _, err = tx.Query(ctx, "SELECT 1"); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Query: got %v, want ErrTxDone", err)
+	}
+	if err = tx.Commit(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Commit: got %v, want ErrTxDone", err)
+	}
+	if err = tx.Rollback(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Rollback: got %v, want ErrTxDone", err)
+	}
+	assertValues(t, db, "1,2")
+}
+
+func TestTxRollback(t *testing.T) {
+	ctx := context.Background()
+	db := openTestTable(t)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, db, "")
+	if err = tx.Commit(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Commit after Rollback: got %v, want ErrTxDone", err)
+	}
+
+	// A new transaction can be started.
+	if tx, err = db.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTxRollbackAfterFailure(t *testing.T) {
+	ctx := context.Background()
+	db := openTestTable(t)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	// The failed statement is undone, but the transaction stays open.
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (1)"); !errors.Is(err, CodeConstraint) {
+		t.Fatalf("got %v, want CodeConstraint", err)
+	}
+	if err = tx.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, db, "")
+
+	// A failed COMMIT leaves the transaction open, so it can still be rolled back. The foreign key is checked on
+	// COMMIT as it is deferred.
+	schema := "PRAGMA foreign_keys = ON; CREATE TABLE child (p REFERENCES t (a) DEFERRABLE INITIALLY DEFERRED)"
+	if err = db.Exec(ctx, schema); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = db.Begin(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO child VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(ctx); !errors.Is(err, CodeConstraint) {
+		t.Fatalf("got %v, want CodeConstraint", err)
+	}
+	if err = tx.Rollback(ctx); err != nil {
+		t.Fatalf("Rollback after a failed Commit: %v", err)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	ctx := context.Background()
+	db := openTestTable(t)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	outer, err := tx.Savepoint(ctx, "outer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+	inner, err := tx.Savepoint(ctx, "inner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (3)"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling back the inner savepoint keeps the changes before it.
+	if err = inner.Rollback(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, db, "1,2")
+	if err = inner.Rollback(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("second Rollback: got %v, want ErrTxDone", err)
+	}
+
+	// Releasing the outer savepoint keeps its changes in the transaction.
+	again, err := tx.Savepoint(ctx, "inner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Exec(ctx, "INSERT INTO t VALUES (4)"); err != nil {
+		t.Fatal(err)
+	}
+	if err = outer.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The nested savepoint was released with the outer one.
+	if err = again.Release(ctx); err == nil {
+		t.Error("released a savepoint released with its parent")
+	}
+	if err = outer.Release(ctx); !errors.Is(err, ErrTxDone) {
+		t.Errorf("second Release: got %v, want ErrTxDone", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertValues(t, db, "1,2,4")
+
+	if _, err = tx.Savepoint(ctx, "late"); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Savepoint after Commit: got %v, want ErrTxDone", err)
+	}
+}
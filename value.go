@@ -0,0 +1,343 @@
+package wazerosqlite
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value is a single SQLite value of any storage class. The zero Value is NULL.
+//
+// Value is shared by the dynamic scanning API and the database/sql driver so that both follow the same type
+// semantics. The conversion methods follow the rules of the sqlite3_value_* functions, e.g. Int64 of the TEXT value
+// "12abc" is 12.
+type Value struct {
+	// typ is the storage class, or zero for NULL.
+	typ ColumnType
+	// i holds the value of TypeInteger.
+	i int64
+	// f holds the value of TypeFloat.
+	f float64
+	// b holds the value of TypeText and TypeBlob.
+	b []byte
+}
+
+// IntegerValue returns the INTEGER Value v.
+func IntegerValue(v int64) Value {
+	return Value{typ: TypeInteger, i: v}
+}
+
+// FloatValue returns the REAL Value v.
+func FloatValue(v float64) Value {
+	return Value{typ: TypeFloat, f: v}
+}
+
+// TextValue returns the TEXT Value v.
+func TextValue(v string) Value {
+	return Value{typ: TypeText, b: []byte(v)}
+}
+
+// BlobValue returns the BLOB Value v. A nil v results in NULL.
+func BlobValue(v []byte) Value {
+	if v == nil {
+		return Value{}
+	}
+	return Value{typ: TypeBlob, b: v}
+}
+
+// NullValue returns the NULL Value.
+func NullValue() Value {
+	return Value{}
+}
+
+// ValueOf converts v to a Value. Supported types are nil, Value, int, int64, uint, uint64, bool, float64, string and
+// []byte. Unsigned integers above math.MaxInt64 return ErrOverflow.
+func ValueOf(v any) (Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return NullValue(), nil
+	case Value:
+		return v, nil
+	case int:
+		return IntegerValue(int64(v)), nil
+	case int64:
+		return IntegerValue(v), nil
+	case uint:
+		return ValueOf(uint64(v))
+	case uint64:
+		i, err := uint64ToInt64(v)
+		if err != nil {
+			return Value{}, err
+		}
+		return IntegerValue(i), nil
+	case bool:
+		if v {
+			return IntegerValue(1), nil
+		}
+		return IntegerValue(0), nil
+	case float64:
+		return FloatValue(v), nil
+	case string:
+		return TextValue(v), nil
+	case []byte:
+		return BlobValue(v), nil
+	}
+	return Value{}, fmt.Errorf("unsupported type %T", v)
+}
+
+// Type returns the storage class of the value.
+func (v Value) Type() ColumnType {
+	if v.typ == 0 {
+		return TypeNull
+	}
+	return v.typ
+}
+
+// IsNull returns true if the value is NULL.
+func (v Value) IsNull() bool {
+	return v.Type() == TypeNull
+}
+
+// Int64 returns the value converted to an integer. REAL values are truncated towards zero, and TEXT and BLOB values
+// are parsed for their longest numeric prefix.
+func (v Value) Int64() int64 {
+	switch v.Type() {
+	case TypeInteger:
+		return v.i
+	case TypeFloat:
+		return floatToInt64(v.f)
+	case TypeText, TypeBlob:
+		return parseNumericPrefix(string(v.b)).Int64()
+	}
+	return 0
+}
+
+// Float64 returns the value converted to a floating point number. TEXT and BLOB values are parsed for their longest
+// numeric prefix.
+func (v Value) Float64() float64 {
+	switch v.Type() {
+	case TypeInteger:
+		return float64(v.i)
+	case TypeFloat:
+		return v.f
+	case TypeText, TypeBlob:
+		return parseNumericPrefix(string(v.b)).Float64()
+	}
+	return 0
+}
+
+// Text returns the value converted to text, rendering numbers the same way SQLite does. NULL is an empty string.
+func (v Value) Text() string {
+	switch v.Type() {
+	case TypeInteger:
+		return strconv.FormatInt(v.i, 10)
+	case TypeFloat:
+		return formatFloat(v.f)
+	case TypeText, TypeBlob:
+		return string(v.b)
+	}
+	return ""
+}
+
+// Blob returns the value converted to bytes. NULL is nil.
+func (v Value) Blob() []byte {
+	switch v.Type() {
+	case TypeText, TypeBlob:
+		return v.b
+	case TypeNull:
+		return nil
+	}
+	return []byte(v.Text())
+}
+
+// Any returns the value as int64, float64, string, []byte or nil depending on its storage class.
+func (v Value) Any() any {
+	switch v.Type() {
+	case TypeInteger:
+		return v.i
+	case TypeFloat:
+		return v.f
+	case TypeText:
+		return string(v.b)
+	case TypeBlob:
+		return v.b
+	}
+	return nil
+}
+
+// String implements fmt.Stringer.
+func (v Value) String() string {
+	if v.IsNull() {
+		return "NULL"
+	}
+	return v.Text()
+}
+
+// Affinity is the type affinity of a column as defined in https://www.sqlite.org/datatype3.html#type_affinity
+type Affinity int
+
+const (
+	// AffinityBlob stores values as-is.
+	AffinityBlob Affinity = iota
+	// AffinityText converts numbers to text.
+	AffinityText
+	// AffinityNumeric converts well-formed numeric text to INTEGER or REAL, and REAL without fractional part to
+	// INTEGER.
+	AffinityNumeric
+	// AffinityInteger behaves like AffinityNumeric.
+	AffinityInteger
+	// AffinityReal behaves like AffinityNumeric except that integers are converted to REAL.
+	AffinityReal
+)
+
+// AffinityOf returns the affinity of a column declared with declType, following SQLite's rules in order:
+// "INT" gives INTEGER, "CHAR", "CLOB" or "TEXT" gives TEXT, "BLOB" or no type gives BLOB, "REAL", "FLOA" or "DOUB"
+// gives REAL, and anything else NUMERIC.
+func AffinityOf(declType string) Affinity {
+	t := strings.ToUpper(declType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return AffinityInteger
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return AffinityText
+	case strings.Contains(t, "BLOB"), strings.TrimSpace(t) == "":
+		return AffinityBlob
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return AffinityReal
+	}
+	return AffinityNumeric
+}
+
+// ApplyAffinity returns v converted the way SQLite converts a value stored into a column with the affinity a.
+func (v Value) ApplyAffinity(a Affinity) Value {
+	switch a {
+	case AffinityText:
+		if t := v.Type(); t == TypeInteger || t == TypeFloat {
+			return TextValue(v.Text())
+		}
+	case AffinityNumeric, AffinityInteger, AffinityReal:
+		n := v
+		if v.Type() == TypeText {
+			parsed, ok := parseNumeric(string(v.b))
+			if !ok {
+				return v
+			}
+			n = parsed
+		}
+
+		switch n.Type() {
+		case TypeInteger:
+			if a == AffinityReal {
+				return FloatValue(float64(n.i))
+			}
+		case TypeFloat:
+			if a != AffinityReal {
+				if i := floatToInt64(n.f); float64(i) == n.f && i != math.MinInt64 && i != math.MaxInt64 {
+					return IntegerValue(i)
+				}
+			}
+		}
+		return n
+	}
+	return v
+}
+
+// parseNumeric parses s as a well-formed integer or real literal surrounded by optional spaces.
+func parseNumeric(s string) (Value, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Value{}, false
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return IntegerValue(i), true
+	}
+	// Hex, infinity and NaN literals are not numeric in SQLite, but they are accepted by strconv.
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789+-.eE", c) {
+			return Value{}, false
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return FloatValue(f), true
+	} else if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return FloatValue(f), true
+	}
+	return Value{}, false
+}
+
+// parseNumericPrefix parses the longest prefix of s which is a number, ignoring leading spaces. Zero is returned if
+// there is none.
+func parseNumericPrefix(s string) Value {
+	s = strings.TrimLeft(s, " \t\n\f\r\v")
+	end, digits, isReal := 0, 0, false
+	if end < len(s) && (s[end] == '+' || s[end] == '-') {
+		end++
+	}
+	for ; end < len(s) && s[end] >= '0' && s[end] <= '9'; end++ {
+		digits++
+	}
+	if end < len(s) && s[end] == '.' {
+		isReal = true
+		for end++; end < len(s) && s[end] >= '0' && s[end] <= '9'; end++ {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return IntegerValue(0)
+	}
+	if end < len(s) && (s[end] == 'e' || s[end] == 'E') {
+		exp := end + 1
+		if exp < len(s) && (s[exp] == '+' || s[exp] == '-') {
+			exp++
+		}
+		if exp < len(s) && s[exp] >= '0' && s[exp] <= '9' {
+			isReal = true
+			for end = exp; end < len(s) && s[end] >= '0' && s[end] <= '9'; end++ {
+			}
+		}
+	}
+
+	if v, ok := parseNumeric(s[:end]); ok {
+		if !isReal && v.Type() == TypeFloat {
+			// Integer literal out of range saturates as sqlite3_value_int64 does.
+			return IntegerValue(floatToInt64(v.f))
+		}
+		return v
+	}
+	return IntegerValue(0)
+}
+
+// floatToInt64 truncates f towards zero, saturating at the int64 bounds as SQLite does.
+func floatToInt64(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return int64(f)
+}
+
+// formatFloat renders f with 15 significant digits like SQLite, which always includes a decimal point.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return ""
+	}
+
+	s := strconv.FormatFloat(f, 'g', 15, 64)
+	if strings.ContainsRune(s, '.') {
+		return s
+	}
+	if i := strings.IndexByte(s, 'e'); i >= 0 {
+		return s[:i] + ".0" + s[i:]
+	}
+	return s + ".0"
+}
@@ -0,0 +1,722 @@
+package wazerosqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasi implements the WASI functions SQLite imports, as the "wasi_snapshot_preview1" host module, in place of the one
+// of wazero. wazero doesn't implement fd_filestat_get, path_filestat_get, fd_sync nor path_unlink_file yet, which
+// SQLite needs to write to database files and to delete its journals, and only opens files through fs.FS, without the
+// flags the guest opens them with.
+//
+// The host directory of WithFile or WithDir is preopened as the root "/", and the guest can't reach outside of it.
+// The other functions behave like those of wazero: the guest has no arguments nor environment variables, writes to
+// stdout and stderr are discarded, and the clocks are fake ones unless WithSystemClock is given.
+type wasi struct {
+	// dir is the host directory preopened as the root, or empty if none is.
+	dir string
+	// sysClock is true to read the host's clocks instead of fake ones.
+	sysClock bool
+	// start is when the host module was created, which the monotonic clock counts from if sysClock is true.
+	start time.Time
+
+	// mu guards instances, as the instances of a Pool call the functions concurrently.
+	mu sync.Mutex
+	// instances are the states of the module instances, keyed by the api.Module the functions are called with.
+	instances map[api.Module]*wasiInstance
+}
+
+// wasiInstance is the state of a module instance.
+type wasiInstance struct {
+	// files are the files opened by the guest, by file descriptor.
+	files map[uint32]*wasiFile
+	// walltime and nanotime are the last readings of the fake clocks, in nanoseconds.
+	walltime, nanotime int64
+}
+
+// wasiFile is a file opened by the guest.
+type wasiFile struct {
+	*os.File
+	// name is the path of the file in the guest, relative to the root.
+	name string
+}
+
+const (
+	// wasiRootFD is the file descriptor of the preopened root directory. Those before it are stdin, stdout and stderr.
+	wasiRootFD = 3
+
+	// fakeEpoch is the first reading of the fake wall clock, and fakeTick is how much the fake clocks advance on each
+	// reading, as in wazero.
+	fakeEpoch = 1640995200 * int64(time.Second)
+	fakeTick  = int64(time.Millisecond)
+)
+
+// WASI file types. See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-filetype-enumu8
+const (
+	fileTypeUnknown         = 0
+	fileTypeCharacterDevice = 2
+	fileTypeDirectory       = 3
+	fileTypeRegularFile     = 4
+	fileTypeSymbolicLink    = 7
+)
+
+// WASI open flags. See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-oflags-flagsu16
+const (
+	oflagCreat     = 1
+	oflagDirectory = 2
+	oflagExcl      = 4
+	oflagTrunc     = 8
+)
+
+// newWASI returns the WASI functions for the configuration c.
+func newWASI(c *config) *wasi {
+	return &wasi{
+		dir:       c.mountedDir(),
+		sysClock:  c.sysClock,
+		start:     time.Now(),
+		instances: map[api.Module]*wasiInstance{},
+	}
+}
+
+// instantiate instantiates the host module in r.
+func (w *wasi) instantiate(ctx context.Context, r wazero.Runtime) error {
+	const nosys = wasi_snapshot_preview1.ErrnoNosys
+	_, err := r.NewModuleBuilder(wasi_snapshot_preview1.ModuleName).ExportFunctions(map[string]interface{}{
+		"args_get":              w.empty2,
+		"args_sizes_get":        w.sizesGet,
+		"environ_get":           w.empty2,
+		"environ_sizes_get":     w.sizesGet,
+		"clock_res_get":         w.clockResGet,
+		"clock_time_get":        w.clockTimeGet,
+		"fd_close":              w.fdClose,
+		"fd_datasync":           w.fdSync,
+		"fd_fdstat_get":         w.fdFdstatGet,
+		"fd_filestat_get":       w.fdFilestatGet,
+		"fd_filestat_set_size":  w.fdFilestatSetSize,
+		"fd_pread":              w.fdPread,
+		"fd_prestat_get":        w.fdPrestatGet,
+		"fd_prestat_dir_name":   w.fdPrestatDirName,
+		"fd_pwrite":             w.fdPwrite,
+		"fd_read":               w.fdRead,
+		"fd_seek":               w.fdSeek,
+		"fd_sync":               w.fdSync,
+		"fd_tell":               w.fdTell,
+		"fd_write":              w.fdWrite,
+		"path_create_directory": w.pathCreateDirectory,
+		"path_filestat_get":     w.pathFilestatGet,
+		"path_open":             w.pathOpen,
+		"path_remove_directory": w.pathRemoveDirectory,
+		"path_rename":           w.pathRename,
+		"path_unlink_file":      w.pathUnlinkFile,
+		"poll_oneoff":           w.pollOneoff,
+		"proc_exit":             w.procExit,
+		"random_get":            w.randomGet,
+		"sched_yield":           func() uint32 { return wasi_snapshot_preview1.ErrnoSuccess },
+
+		// The functions SQLite doesn't use are only there so that any build of it can be instantiated.
+		"fd_advise":               func(_ uint32, _, _ uint64, _ uint32) uint32 { return nosys },
+		"fd_allocate":             func(_ uint32, _, _ uint64) uint32 { return nosys },
+		"fd_fdstat_set_flags":     func(_, _ uint32) uint32 { return nosys },
+		"fd_fdstat_set_rights":    func(_ uint32, _, _ uint64) uint32 { return nosys },
+		"fd_filestat_set_times":   func(_ uint32, _, _ uint64, _ uint32) uint32 { return nosys },
+		"fd_readdir":              func(_, _, _ uint32, _ uint64, _ uint32) uint32 { return nosys },
+		"fd_renumber":             func(_, _ uint32) uint32 { return nosys },
+		"path_filestat_set_times": func(_, _, _, _ uint32, _, _ uint64, _ uint32) uint32 { return nosys },
+		"path_link":               func(_, _, _, _, _, _, _ uint32) uint32 { return nosys },
+		"path_readlink":           func(_, _, _, _, _, _ uint32) uint32 { return nosys },
+		"path_symlink":            func(_, _, _, _, _ uint32) uint32 { return nosys },
+		"proc_raise":              func(uint32) uint32 { return nosys },
+		"sock_recv":               func(_, _, _, _, _, _ uint32) uint32 { return nosys },
+		"sock_send":               func(_, _, _, _, _ uint32) uint32 { return nosys },
+		"sock_shutdown":           func(_, _ uint32) uint32 { return nosys },
+	}).Instantiate(ctx, r)
+	return err
+}
+
+// instance returns the state of the module instance mod.
+func (w *wasi) instance(mod api.Module) *wasiInstance {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	inst, ok := w.instances[mod]
+	if !ok {
+		inst = &wasiInstance{files: map[uint32]*wasiFile{}, walltime: fakeEpoch - fakeTick, nanotime: -fakeTick}
+		w.instances[mod] = inst
+	}
+	return inst
+}
+
+// release closes the files opened by mod and forgets its state, once the module instance is closed.
+func (w *wasi) release(mod api.Module) {
+	w.mu.Lock()
+	inst, ok := w.instances[mod]
+	delete(w.instances, mod)
+	w.mu.Unlock()
+	if ok {
+		for _, f := range inst.files {
+			_ = f.Close()
+		}
+	}
+}
+
+// file returns the file opened as fd by mod.
+func (w *wasi) file(mod api.Module, fd uint32) (*wasiFile, bool) {
+	f, ok := w.instance(mod).files[fd]
+	return f, ok
+}
+
+// path reads the guest path at offset in the memory of mod, and returns the corresponding path on the host. dirFD must
+// be the root, as it's the only directory the guest can open files in.
+func (w *wasi) path(ctx context.Context, mod api.Module, dirFD, offset, length uint32) (string, uint32) {
+	if dirFD != wasiRootFD || w.dir == "" {
+		return "", wasi_snapshot_preview1.ErrnoBadf
+	}
+	b, ok := mod.Memory().Read(ctx, offset, length)
+	if !ok {
+		return "", wasi_snapshot_preview1.ErrnoFault
+	}
+	name := string(b)
+	if name == "" {
+		name = "."
+	}
+	// Paths like "../x" would escape the root.
+	if !fs.ValidPath(name) {
+		return "", wasi_snapshot_preview1.ErrnoNotcapable
+	}
+	return filepath.Join(w.dir, filepath.FromSlash(name)), wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// empty2 implements args_get and environ_get, which have nothing to write.
+func (w *wasi) empty2(uint32, uint32) uint32 {
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// sizesGet implements args_sizes_get and environ_sizes_get, and returns that there's nothing.
+func (w *wasi) sizesGet(ctx context.Context, mod api.Module, resultCount, resultBufSize uint32) uint32 {
+	if !mod.Memory().WriteUint32Le(ctx, resultCount, 0) || !mod.Memory().WriteUint32Le(ctx, resultBufSize, 0) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// clockResGet implements clock_res_get.
+func (w *wasi) clockResGet(ctx context.Context, mod api.Module, id, resultResolution uint32) uint32 {
+	if id > 1 {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	if !mod.Memory().WriteUint64Le(ctx, resultResolution, 1) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// clockTimeGet implements clock_time_get for the realtime (0) and monotonic (1) clocks.
+func (w *wasi) clockTimeGet(ctx context.Context, mod api.Module, id uint32, _ uint64, resultTimestamp uint32) uint32 {
+	inst := w.instance(mod)
+	var t int64
+	switch {
+	case id == 0 && w.sysClock:
+		t = time.Now().UnixNano()
+	case id == 0:
+		inst.walltime += fakeTick
+		t = inst.walltime
+	case id == 1 && w.sysClock:
+		t = int64(time.Since(w.start))
+	case id == 1:
+		inst.nanotime += fakeTick
+		t = inst.nanotime
+	default:
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	if !mod.Memory().WriteUint64Le(ctx, resultTimestamp, uint64(t)) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// fdClose implements fd_close.
+func (w *wasi) fdClose(mod api.Module, fd uint32) uint32 {
+	inst := w.instance(mod)
+	f, ok := inst.files[fd]
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	delete(inst.files, fd)
+	return errno(f.Close())
+}
+
+// fdFdstatGet implements fd_fdstat_get. All the rights are given, as they are checked on the host.
+func (w *wasi) fdFdstatGet(ctx context.Context, mod api.Module, fd, resultStat uint32) uint32 {
+	var fileType byte
+	switch f, ok := w.file(mod, fd); {
+	case fd <= 2:
+		fileType = fileTypeCharacterDevice
+	case fd == wasiRootFD && w.dir != "":
+		fileType = fileTypeDirectory
+	case ok:
+		info, err := f.Stat()
+		if err != nil {
+			return errno(err)
+		}
+		fileType = wasiFileType(info.Mode())
+	default:
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+
+	buf := make([]byte, 24)
+	buf[0] = fileType
+	binary.LittleEndian.PutUint64(buf[8:], ^uint64(0))
+	binary.LittleEndian.PutUint64(buf[16:], ^uint64(0))
+	if !mod.Memory().Write(ctx, resultStat, buf) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// fdFilestatGet implements fd_filestat_get.
+func (w *wasi) fdFilestatGet(ctx context.Context, mod api.Module, fd, resultBuf uint32) uint32 {
+	var info fs.FileInfo
+	var name string
+	switch f, ok := w.file(mod, fd); {
+	case fd <= 2:
+		return writeFilestat(ctx, mod, resultBuf, nil, "")
+	case fd == wasiRootFD && w.dir != "":
+		var err error
+		if info, err = os.Stat(w.dir); err != nil {
+			return errno(err)
+		}
+		name = "."
+	case ok:
+		var err error
+		if info, err = f.Stat(); err != nil {
+			return errno(err)
+		}
+		name = f.name
+	default:
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return writeFilestat(ctx, mod, resultBuf, info, name)
+}
+
+// fdFilestatSetSize implements fd_filestat_set_size.
+func (w *wasi) fdFilestatSetSize(mod api.Module, fd uint32, size uint64) uint32 {
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return errno(f.Truncate(int64(size)))
+}
+
+// fdPread implements fd_pread.
+func (w *wasi) fdPread(ctx context.Context, mod api.Module, fd, iovs, iovsCount uint32, offset uint64, resultSize uint32) uint32 {
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return readWrite(ctx, mod, iovs, iovsCount, resultSize, func(b []byte) (int, error) {
+		n, err := f.ReadAt(b, int64(offset))
+		offset += uint64(n)
+		return n, err
+	})
+}
+
+// fdPrestatGet implements fd_prestat_get, and returns that the root is the only preopened directory.
+func (w *wasi) fdPrestatGet(ctx context.Context, mod api.Module, fd, resultPrestat uint32) uint32 {
+	if fd != wasiRootFD || w.dir == "" {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	// The tag is 0 for a directory, followed by the length of its name.
+	if !mod.Memory().WriteUint32Le(ctx, resultPrestat, 0) || !mod.Memory().WriteUint32Le(ctx, resultPrestat+4, 1) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// fdPrestatDirName implements fd_prestat_dir_name.
+func (w *wasi) fdPrestatDirName(ctx context.Context, mod api.Module, fd, path, pathLen uint32) uint32 {
+	if fd != wasiRootFD || w.dir == "" {
+		return wasi_snapshot_preview1.ErrnoBadf
+	} else if pathLen < 1 {
+		return wasi_snapshot_preview1.ErrnoNametoolong
+	}
+	if !mod.Memory().WriteByte(ctx, path, '/') {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// fdPwrite implements fd_pwrite.
+func (w *wasi) fdPwrite(ctx context.Context, mod api.Module, fd, iovs, iovsCount uint32, offset uint64, resultSize uint32) uint32 {
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return readWrite(ctx, mod, iovs, iovsCount, resultSize, func(b []byte) (int, error) {
+		n, err := f.WriteAt(b, int64(offset))
+		offset += uint64(n)
+		return n, err
+	})
+}
+
+// fdRead implements fd_read. stdin is empty.
+func (w *wasi) fdRead(ctx context.Context, mod api.Module, fd, iovs, iovsCount, resultSize uint32) uint32 {
+	if fd == 0 {
+		return readWrite(ctx, mod, iovs, iovsCount, resultSize, func([]byte) (int, error) { return 0, io.EOF })
+	}
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return readWrite(ctx, mod, iovs, iovsCount, resultSize, f.Read)
+}
+
+// fdSeek implements fd_seek. The values of whence are the same as those of io.Seeker.
+func (w *wasi) fdSeek(ctx context.Context, mod api.Module, fd uint32, offset uint64, whence, resultNewOffset uint32) uint32 {
+	f, ok := w.file(mod, fd)
+	if !ok {
+		if fd <= 2 {
+			return wasi_snapshot_preview1.ErrnoSpipe
+		}
+		return wasi_snapshot_preview1.ErrnoBadf
+	} else if whence > io.SeekEnd {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	n, err := f.Seek(int64(offset), int(whence))
+	if err != nil {
+		return errno(err)
+	}
+	if !mod.Memory().WriteUint64Le(ctx, resultNewOffset, uint64(n)) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// fdSync implements fd_sync and fd_datasync.
+func (w *wasi) fdSync(mod api.Module, fd uint32) uint32 {
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return errno(f.Sync())
+}
+
+// fdTell implements fd_tell.
+func (w *wasi) fdTell(ctx context.Context, mod api.Module, fd, resultOffset uint32) uint32 {
+	return w.fdSeek(ctx, mod, fd, 0, io.SeekCurrent, resultOffset)
+}
+
+// fdWrite implements fd_write. What's written to stdout and stderr is discarded.
+func (w *wasi) fdWrite(ctx context.Context, mod api.Module, fd, iovs, iovsCount, resultSize uint32) uint32 {
+	if fd == 1 || fd == 2 {
+		return readWrite(ctx, mod, iovs, iovsCount, resultSize, func(b []byte) (int, error) { return len(b), nil })
+	}
+	f, ok := w.file(mod, fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	return readWrite(ctx, mod, iovs, iovsCount, resultSize, f.Write)
+}
+
+// pathCreateDirectory implements path_create_directory.
+func (w *wasi) pathCreateDirectory(ctx context.Context, mod api.Module, fd, path, pathLen uint32) uint32 {
+	name, e := w.path(ctx, mod, fd, path, pathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	return errno(os.Mkdir(name, 0o755))
+}
+
+// pathFilestatGet implements path_filestat_get.
+func (w *wasi) pathFilestatGet(ctx context.Context, mod api.Module, fd, flags, path, pathLen, resultBuf uint32) uint32 {
+	name, e := w.path(ctx, mod, fd, path, pathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	stat := os.Lstat
+	if flags&1 != 0 { // symlink_follow
+		stat = os.Stat
+	}
+	info, err := stat(name)
+	if err != nil {
+		return errno(err)
+	}
+	guestName, _ := filepath.Rel(w.dir, name)
+	return writeFilestat(ctx, mod, resultBuf, info, filepath.ToSlash(guestName))
+}
+
+// pathOpen implements path_open.
+//
+// Files are opened for both reading and writing, or read-only if the host doesn't allow writing to them, regardless of
+// the rights the guest asks for, which some builds of SQLite don't set.
+func (w *wasi) pathOpen(ctx context.Context, mod api.Module, fd, _, path, pathLen, oflags uint32, _, _ uint64, fdflags, resultOpenedFD uint32) uint32 {
+	name, e := w.path(ctx, mod, fd, path, pathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+
+	var f *os.File
+	var err error
+	if oflags&oflagDirectory != 0 {
+		if f, err = os.Open(name); err == nil {
+			if info, statErr := f.Stat(); statErr != nil || !info.IsDir() {
+				_ = f.Close()
+				return wasi_snapshot_preview1.ErrnoNotdir
+			}
+		}
+	} else {
+		flag := os.O_RDWR
+		if oflags&oflagCreat != 0 {
+			flag |= os.O_CREATE
+		}
+		if oflags&oflagExcl != 0 {
+			flag |= os.O_EXCL
+		}
+		if oflags&oflagTrunc != 0 {
+			flag |= os.O_TRUNC
+		}
+		if fdflags&1 != 0 { // append
+			flag |= os.O_APPEND
+		}
+		f, err = os.OpenFile(name, flag, 0o644)
+		if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS) {
+			f, err = os.Open(name)
+		}
+	}
+	if err != nil {
+		return errno(err)
+	}
+
+	inst := w.instance(mod)
+	newFD := uint32(wasiRootFD + 1)
+	for inst.files[newFD] != nil {
+		newFD++
+	}
+	guestName, _ := filepath.Rel(w.dir, name)
+	inst.files[newFD] = &wasiFile{File: f, name: filepath.ToSlash(guestName)}
+	if !mod.Memory().WriteUint32Le(ctx, resultOpenedFD, newFD) {
+		delete(inst.files, newFD)
+		_ = f.Close()
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// pathRemoveDirectory implements path_remove_directory.
+func (w *wasi) pathRemoveDirectory(ctx context.Context, mod api.Module, fd, path, pathLen uint32) uint32 {
+	name, e := w.path(ctx, mod, fd, path, pathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	if info, err := os.Lstat(name); err != nil {
+		return errno(err)
+	} else if !info.IsDir() {
+		return wasi_snapshot_preview1.ErrnoNotdir
+	}
+	return errno(os.Remove(name))
+}
+
+// pathRename implements path_rename.
+func (w *wasi) pathRename(ctx context.Context, mod api.Module, fd, oldPath, oldPathLen, newFD, newPath, newPathLen uint32) uint32 {
+	oldName, e := w.path(ctx, mod, fd, oldPath, oldPathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	newName, e := w.path(ctx, mod, newFD, newPath, newPathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	return errno(os.Rename(oldName, newName))
+}
+
+// pathUnlinkFile implements path_unlink_file.
+func (w *wasi) pathUnlinkFile(ctx context.Context, mod api.Module, fd, path, pathLen uint32) uint32 {
+	name, e := w.path(ctx, mod, fd, path, pathLen)
+	if e != wasi_snapshot_preview1.ErrnoSuccess {
+		return e
+	}
+	if info, err := os.Lstat(name); err != nil {
+		return errno(err)
+	} else if info.IsDir() {
+		return wasi_snapshot_preview1.ErrnoIsdir
+	}
+	return errno(os.Remove(name))
+}
+
+// pollOneoff implements poll_oneoff for relative clock subscriptions, which is how the guest sleeps, e.g. between the
+// retries of a busy database. Like in wazero, the sleep returns immediately unless WithSystemClock is given.
+func (w *wasi) pollOneoff(ctx context.Context, mod api.Module, in, out, nsubscriptions, resultNevents uint32) uint32 {
+	if nsubscriptions == 0 {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	mem := mod.Memory()
+	inBuf, ok := mem.Read(ctx, in, nsubscriptions*48)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	outBuf, ok := mem.Read(ctx, out, nsubscriptions*32)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+
+	var sleep time.Duration
+	for i := uint32(0); i < nsubscriptions; i++ {
+		sub, event := inBuf[i*48:(i+1)*48], outBuf[i*32:(i+1)*32]
+		// The subscription is the userdata, the event type, and then the clock ID, the timeout, the precision and
+		// the flags of clock subscriptions. The event is the userdata, the error and the event type.
+		e := wasi_snapshot_preview1.ErrnoNotsup
+		switch eventType := sub[8]; {
+		case eventType > 2:
+			return wasi_snapshot_preview1.ErrnoInval
+		case eventType == 0 && binary.LittleEndian.Uint16(sub[40:]) == 0: // relative
+			if timeout := time.Duration(binary.LittleEndian.Uint64(sub[24:])); timeout > sleep {
+				sleep = timeout
+			}
+			e = wasi_snapshot_preview1.ErrnoSuccess
+		}
+		copy(event, sub[:8])
+		binary.LittleEndian.PutUint16(event[8:], uint16(e))
+		binary.LittleEndian.PutUint16(event[10:], uint16(sub[8]))
+	}
+	if !mem.WriteUint32Le(ctx, resultNevents, nsubscriptions) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	if w.sysClock && sleep > 0 {
+		time.Sleep(sleep)
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// procExit implements proc_exit, which closes the module instance.
+func (w *wasi) procExit(ctx context.Context, mod api.Module, exitCode uint32) {
+	_ = mod.CloseWithExitCode(ctx, exitCode)
+	// Prevent the guest from running after exit.
+	panic(sys.NewExitError(mod.Name(), exitCode))
+}
+
+// randomGet implements random_get with crypto/rand.
+func (w *wasi) randomGet(ctx context.Context, mod api.Module, buf, bufLen uint32) uint32 {
+	b, ok := mod.Memory().Read(ctx, buf, bufLen)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	if _, err := rand.Read(b); err != nil {
+		return wasi_snapshot_preview1.ErrnoIo
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// readWrite calls f with each of the iovsCount buffers described at iovs, until it reads or writes less than a whole
+// buffer, and writes the number of bytes read or written at resultSize.
+func readWrite(ctx context.Context, mod api.Module, iovs, iovsCount, resultSize uint32, f func([]byte) (int, error)) uint32 {
+	mem := mod.Memory()
+	var total uint32
+	for i := uint32(0); i < iovsCount; i++ {
+		offset, ok := mem.ReadUint32Le(ctx, iovs+i*8)
+		if !ok {
+			return wasi_snapshot_preview1.ErrnoFault
+		}
+		length, ok := mem.ReadUint32Le(ctx, iovs+i*8+4)
+		if !ok {
+			return wasi_snapshot_preview1.ErrnoFault
+		}
+		b, ok := mem.Read(ctx, offset, length)
+		if !ok {
+			return wasi_snapshot_preview1.ErrnoFault
+		}
+		n, err := f(b)
+		total += uint32(n)
+		if err != nil && !errors.Is(err, io.EOF) && total == 0 {
+			return errno(err)
+		} else if err != nil || n < len(b) {
+			break
+		}
+	}
+	if !mem.WriteUint32Le(ctx, resultSize, total) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// writeFilestat writes the filestat of the file at name in the guest, or of a character device if info is nil, at
+// offset in the memory of mod.
+//
+// The inode is a hash of the name, as fs.FileInfo doesn't have it on all platforms. SQLite uses it to tell whether
+// two names refer to the same file.
+func writeFilestat(ctx context.Context, mod api.Module, offset uint32, info fs.FileInfo, name string) uint32 {
+	buf := make([]byte, 64)
+	if info == nil {
+		buf[16] = fileTypeCharacterDevice
+	} else {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(name))
+		binary.LittleEndian.PutUint64(buf[8:], h.Sum64())
+		buf[16] = wasiFileType(info.Mode())
+		binary.LittleEndian.PutUint64(buf[24:], 1) // nlink
+		binary.LittleEndian.PutUint64(buf[32:], uint64(info.Size()))
+		mtime := uint64(info.ModTime().UnixNano())
+		binary.LittleEndian.PutUint64(buf[40:], mtime)
+		binary.LittleEndian.PutUint64(buf[48:], mtime)
+		binary.LittleEndian.PutUint64(buf[56:], mtime)
+	}
+	if !mod.Memory().Write(ctx, offset, buf) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// wasiFileType returns the WASI file type of mode.
+func wasiFileType(mode fs.FileMode) byte {
+	switch {
+	case mode.IsRegular():
+		return fileTypeRegularFile
+	case mode.IsDir():
+		return fileTypeDirectory
+	case mode&fs.ModeSymlink != 0:
+		return fileTypeSymbolicLink
+	case mode&fs.ModeCharDevice != 0:
+		return fileTypeCharacterDevice
+	}
+	return fileTypeUnknown
+}
+
+// errno returns the WASI error number of err from the host.
+func errno(err error) uint32 {
+	switch {
+	case err == nil:
+		return wasi_snapshot_preview1.ErrnoSuccess
+	case errors.Is(err, fs.ErrNotExist):
+		return wasi_snapshot_preview1.ErrnoNoent
+	case errors.Is(err, fs.ErrExist):
+		return wasi_snapshot_preview1.ErrnoExist
+	case errors.Is(err, fs.ErrPermission):
+		return wasi_snapshot_preview1.ErrnoAcces
+	case errors.Is(err, syscall.EISDIR):
+		return wasi_snapshot_preview1.ErrnoIsdir
+	case errors.Is(err, syscall.ENOTDIR):
+		return wasi_snapshot_preview1.ErrnoNotdir
+	case errors.Is(err, syscall.ENOTEMPTY):
+		return wasi_snapshot_preview1.ErrnoNotempty
+	case errors.Is(err, syscall.EROFS):
+		return wasi_snapshot_preview1.ErrnoRofs
+	case errors.Is(err, syscall.ENOSPC):
+		return wasi_snapshot_preview1.ErrnoNospc
+	case errors.Is(err, syscall.EINVAL):
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	return wasi_snapshot_preview1.ErrnoIo
+}
@@ -0,0 +1,89 @@
+package wazerosqlite
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileDatabase(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.db")
+
+	db, err := Open(ctx, WithFile(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Exec(ctx, "CREATE TABLE t (a); BEGIN; INSERT INTO t VALUES ('x'); INSERT INTO t VALUES ('y'); COMMIT"); err != nil {
+		t.Fatal(err)
+	}
+	// SQLite deletes the rollback journal on commit.
+	if _, err = os.Stat(file + "-journal"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("journal left after commit: %v", err)
+	}
+	if err = db.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if db, err = Open(ctx, WithFile(file)); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	got, err := queryStrings(t, db, "SELECT group_concat(a) FROM t")
+	if err != nil || got[0] != "x,y" {
+		t.Errorf("got %v, %v after reopening", got, err)
+	}
+}
+
+func TestFileOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "db")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(ctx, WithFile(filepath.Join(dir, "test.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close(ctx)
+	if err = db.Exec(ctx, "ATTACH '/../outside.db' AS outside"); err == nil {
+		t.Error("attached a database outside of the mounted directory")
+	}
+	if _, err = os.Stat(filepath.Join(parent, "outside.db")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("outside.db was created: %v", err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		// The fake clock starts at 2022-01-01.
+		{name: "fake", want: "2022"},
+		{name: "system", opts: []Option{WithSystemClock()}, want: strconv.Itoa(time.Now().UTC().Year())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := Open(ctx, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close(ctx)
+			got, err := queryStrings(t, db, "SELECT strftime('%Y', 'now')")
+			if err != nil || got[0] != tt.want {
+				t.Errorf("got %v, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}